@@ -3,6 +3,8 @@ package client
 import (
 	"context"
 	"io"
+	"sync"
+	"time"
 
 	"github.com/andres-teleport/overseer/api"
 	"github.com/andres-teleport/overseer/api/authentication"
@@ -28,11 +30,15 @@ func NewClient(serverAddr, keyFile, certFile, caFile string) (*Client, error) {
 }
 
 func (c *Client) Start(ctx context.Context, command string, arguments ...string) (string, error) {
-	job := &api.Job{
+	return c.StartJob(ctx, &api.Job{
 		Command:   command,
 		Arguments: arguments,
-	}
+	})
+}
 
+// StartJob starts the given job, allowing to set all of its options such as
+// the resource limits
+func (c *Client) StartJob(ctx context.Context, job *api.Job) (string, error) {
 	jobID, err := c.client.Start(ctx, job)
 	if err != nil {
 		return "", err
@@ -41,8 +47,52 @@ func (c *Client) Start(ctx context.Context, command string, arguments ...string)
 	return jobID.Id, nil
 }
 
+// Exec starts the given job in the cgroup of the running job with the given ID,
+// sharing its resource limits, and returns the ID of the new job
+func (c *Client) Exec(ctx context.Context, jobID string, job *api.Job) (string, error) {
+	resp, err := c.client.Exec(ctx, &api.ExecRequest{Id: jobID, Job: job})
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Id, nil
+}
+
 func (c *Client) Stop(ctx context.Context, jobID string) error {
-	_, err := c.client.Stop(ctx, &api.JobID{Id: jobID})
+	_, err := c.client.Stop(ctx, &api.StopRequest{Id: jobID})
+	return err
+}
+
+// StopGracefully sends the given signal (e.g. "SIGTERM") to the job and kills
+// it if it is still running after the grace period, a zero grace period means
+// the server default
+func (c *Client) StopGracefully(ctx context.Context, jobID, signal string, grace time.Duration) error {
+	_, err := c.client.Stop(ctx, &api.StopRequest{
+		Id:                jobID,
+		Signal:            signal,
+		GracePeriodMillis: grace.Milliseconds(),
+	})
+	return err
+}
+
+// Signal sends the given signal (e.g. "SIGHUP") to the main process of the
+// job, or to all of its processes if group is true
+func (c *Client) Signal(ctx context.Context, jobID, signal string, group bool) error {
+	_, err := c.client.Signal(ctx, &api.SignalRequest{
+		Id:     jobID,
+		Signal: signal,
+		Group:  group,
+	})
+	return err
+}
+
+func (c *Client) Pause(ctx context.Context, jobID string) error {
+	_, err := c.client.Pause(ctx, &api.JobID{Id: jobID})
+	return err
+}
+
+func (c *Client) Resume(ctx context.Context, jobID string) error {
+	_, err := c.client.Resume(ctx, &api.JobID{Id: jobID})
 	return err
 }
 
@@ -50,6 +100,94 @@ func (c *Client) Status(ctx context.Context, jobID string) (*api.StatusResponse,
 	return c.client.Status(ctx, &api.JobID{Id: jobID})
 }
 
+// Wait blocks until the job has finished and returns its final status
+func (c *Client) Wait(ctx context.Context, jobID string) (*api.StatusResponse, error) {
+	return c.client.Wait(ctx, &api.JobID{Id: jobID})
+}
+
+// List returns a page of the jobs of the caller matching the request
+func (c *Client) List(ctx context.Context, req *api.ListRequest) (*api.ListResponse, error) {
+	return c.client.List(ctx, req)
+}
+
+// ListAll returns all the jobs of the caller matching the request, following
+// the pages from the given page token
+func (c *Client) ListAll(ctx context.Context, req *api.ListRequest) ([]*api.JobInfo, error) {
+	var jobs []*api.JobInfo
+
+	for {
+		resp, err := c.client.List(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, resp.Jobs...)
+		if resp.NextPageToken == "" {
+			return jobs, nil
+		}
+
+		req = &api.ListRequest{
+			Statuses:  req.Statuses,
+			Labels:    req.Labels,
+			PageSize:  req.PageSize,
+			PageToken: resp.NextPageToken,
+		}
+	}
+}
+
+// Watch calls fn with the lifecycle events of the given job, or of all the
+// jobs of the caller if jobID is empty, until the context is cancelled or fn
+// returns an error. Watching a single job ends once it has finished.
+func (c *Client) Watch(ctx context.Context, jobID string, fn func(*api.JobEvent) error) error {
+	stream, err := c.client.Watch(ctx, &api.WatchRequest{JobId: jobID})
+	if err != nil {
+		return err
+	}
+
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if err = fn(ev); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) Stats(ctx context.Context, jobID string) (*api.StatsResponse, error) {
+	return c.client.Stats(ctx, &api.JobID{Id: jobID})
+}
+
+// StreamStats calls fn with the resource usage of the given job every
+// interval, a zero interval meaning the server default, until the job finishes,
+// the context is cancelled or fn returns an error
+func (c *Client) StreamStats(ctx context.Context, jobID string, interval time.Duration, fn func(*api.StatsResponse) error) error {
+	stream, err := c.client.StreamStats(ctx, &api.StreamStatsRequest{
+		Id:             jobID,
+		IntervalMillis: interval.Milliseconds(),
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		st, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if err = fn(st); err != nil {
+			return err
+		}
+	}
+}
+
 func copyStream(ctx context.Context, streamer api.JobworkerService_StdOutClient, w *io.PipeWriter) {
 	for eof := false; !eof; {
 		chunk, err := streamer.Recv()
@@ -94,3 +232,123 @@ func (c *Client) StdErr(ctx context.Context, jobID string) (*io.PipeReader, erro
 
 	return pr, nil
 }
+
+// StdIn sends the data read from r to the standard input of the job until EOF,
+// then closes it
+func (c *Client) StdIn(ctx context.Context, jobID string, r io.Reader) error {
+	stream, err := c.client.StdIn(ctx)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 8192)
+	for eof := false; !eof; {
+		n, err := r.Read(buf)
+		if err == io.EOF {
+			eof = true
+		} else if err != nil {
+			return err
+		}
+
+		if n == 0 && !eof {
+			continue
+		}
+
+		// The server returned an error, which is received by CloseAndRecv
+		if err := stream.Send(&api.StdInChunk{
+			Id:    jobID,
+			Input: buf[:n],
+			Close: eof,
+		}); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	_, err = stream.CloseAndRecv()
+	return err
+}
+
+// Attachment is a connection to the terminal of a job, its methods can be
+// called concurrently
+type Attachment struct {
+	jobID  string
+	stream api.JobworkerService_AttachClient
+	mu     sync.Mutex
+}
+
+// Attach connects to the terminal of the given job, setting its window size
+// unless rows or cols are zero. The output produced before attaching is only
+// received if replay is true.
+func (c *Client) Attach(ctx context.Context, jobID string, rows, cols uint16, replay bool) (*Attachment, error) {
+	stream, err := c.client.Attach(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Attachment{jobID: jobID, stream: stream}
+
+	// The first request identifies the job even without a window size
+	if err := a.send(&api.AttachRequest{Resize: windowSize(rows, cols), Replay: replay}); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func windowSize(rows, cols uint16) *api.WindowSize {
+	if rows == 0 || cols == 0 {
+		return nil
+	}
+
+	return &api.WindowSize{Rows: uint32(rows), Cols: uint32(cols)}
+}
+
+func (a *Attachment) send(req *api.AttachRequest) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	req.Id = a.jobID
+
+	return a.stream.Send(req)
+}
+
+// Write sends the given input to the terminal of the job
+func (a *Attachment) Write(p []byte) (int, error) {
+	if err := a.send(&api.AttachRequest{Input: p}); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Resize sets the window size of the terminal of the job
+func (a *Attachment) Resize(rows, cols uint16) error {
+	return a.send(&api.AttachRequest{Resize: windowSize(rows, cols)})
+}
+
+// Output copies the output of the terminal to w until the job finishes or the
+// detachment is acknowledged by the server
+func (a *Attachment) Output(w io.Writer) error {
+	for {
+		resp, err := a.stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(resp.Output); err != nil {
+			return err
+		}
+	}
+}
+
+// Detach stops sending input to the job, leaving it running
+func (a *Attachment) Detach() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.stream.CloseSend()
+}
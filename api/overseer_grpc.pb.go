@@ -19,10 +19,21 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type JobworkerServiceClient interface {
 	Start(ctx context.Context, in *Job, opts ...grpc.CallOption) (*JobID, error)
-	Stop(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*StopResponse, error)
+	Exec(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (*JobID, error)
+	Stop(ctx context.Context, in *StopRequest, opts ...grpc.CallOption) (*StopResponse, error)
+	Signal(ctx context.Context, in *SignalRequest, opts ...grpc.CallOption) (*SignalResponse, error)
+	Pause(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*PauseResponse, error)
+	Resume(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*ResumeResponse, error)
 	Status(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*StatusResponse, error)
+	Wait(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*StatusResponse, error)
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (JobworkerService_WatchClient, error)
+	Stats(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*StatsResponse, error)
+	StreamStats(ctx context.Context, in *StreamStatsRequest, opts ...grpc.CallOption) (JobworkerService_StreamStatsClient, error)
 	StdOut(ctx context.Context, in *JobID, opts ...grpc.CallOption) (JobworkerService_StdOutClient, error)
 	StdErr(ctx context.Context, in *JobID, opts ...grpc.CallOption) (JobworkerService_StdErrClient, error)
+	StdIn(ctx context.Context, opts ...grpc.CallOption) (JobworkerService_StdInClient, error)
+	Attach(ctx context.Context, opts ...grpc.CallOption) (JobworkerService_AttachClient, error)
 }
 
 type jobworkerServiceClient struct {
@@ -42,7 +53,16 @@ func (c *jobworkerServiceClient) Start(ctx context.Context, in *Job, opts ...grp
 	return out, nil
 }
 
-func (c *jobworkerServiceClient) Stop(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*StopResponse, error) {
+func (c *jobworkerServiceClient) Exec(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (*JobID, error) {
+	out := new(JobID)
+	err := c.cc.Invoke(ctx, "/overseer.JobworkerService/Exec", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobworkerServiceClient) Stop(ctx context.Context, in *StopRequest, opts ...grpc.CallOption) (*StopResponse, error) {
 	out := new(StopResponse)
 	err := c.cc.Invoke(ctx, "/overseer.JobworkerService/Stop", in, out, opts...)
 	if err != nil {
@@ -51,6 +71,33 @@ func (c *jobworkerServiceClient) Stop(ctx context.Context, in *JobID, opts ...gr
 	return out, nil
 }
 
+func (c *jobworkerServiceClient) Signal(ctx context.Context, in *SignalRequest, opts ...grpc.CallOption) (*SignalResponse, error) {
+	out := new(SignalResponse)
+	err := c.cc.Invoke(ctx, "/overseer.JobworkerService/Signal", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobworkerServiceClient) Pause(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*PauseResponse, error) {
+	out := new(PauseResponse)
+	err := c.cc.Invoke(ctx, "/overseer.JobworkerService/Pause", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobworkerServiceClient) Resume(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*ResumeResponse, error) {
+	out := new(ResumeResponse)
+	err := c.cc.Invoke(ctx, "/overseer.JobworkerService/Resume", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *jobworkerServiceClient) Status(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*StatusResponse, error) {
 	out := new(StatusResponse)
 	err := c.cc.Invoke(ctx, "/overseer.JobworkerService/Status", in, out, opts...)
@@ -60,8 +107,99 @@ func (c *jobworkerServiceClient) Status(ctx context.Context, in *JobID, opts ...
 	return out, nil
 }
 
+func (c *jobworkerServiceClient) Wait(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*StatusResponse, error) {
+	out := new(StatusResponse)
+	err := c.cc.Invoke(ctx, "/overseer.JobworkerService/Wait", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobworkerServiceClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, "/overseer.JobworkerService/List", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobworkerServiceClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (JobworkerService_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &JobworkerService_ServiceDesc.Streams[0], "/overseer.JobworkerService/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &jobworkerServiceWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type JobworkerService_WatchClient interface {
+	Recv() (*JobEvent, error)
+	grpc.ClientStream
+}
+
+type jobworkerServiceWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *jobworkerServiceWatchClient) Recv() (*JobEvent, error) {
+	m := new(JobEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *jobworkerServiceClient) Stats(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*StatsResponse, error) {
+	out := new(StatsResponse)
+	err := c.cc.Invoke(ctx, "/overseer.JobworkerService/Stats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobworkerServiceClient) StreamStats(ctx context.Context, in *StreamStatsRequest, opts ...grpc.CallOption) (JobworkerService_StreamStatsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &JobworkerService_ServiceDesc.Streams[1], "/overseer.JobworkerService/StreamStats", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &jobworkerServiceStreamStatsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type JobworkerService_StreamStatsClient interface {
+	Recv() (*StatsResponse, error)
+	grpc.ClientStream
+}
+
+type jobworkerServiceStreamStatsClient struct {
+	grpc.ClientStream
+}
+
+func (x *jobworkerServiceStreamStatsClient) Recv() (*StatsResponse, error) {
+	m := new(StatsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *jobworkerServiceClient) StdOut(ctx context.Context, in *JobID, opts ...grpc.CallOption) (JobworkerService_StdOutClient, error) {
-	stream, err := c.cc.NewStream(ctx, &JobworkerService_ServiceDesc.Streams[0], "/overseer.JobworkerService/StdOut", opts...)
+	stream, err := c.cc.NewStream(ctx, &JobworkerService_ServiceDesc.Streams[2], "/overseer.JobworkerService/StdOut", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -93,7 +231,7 @@ func (x *jobworkerServiceStdOutClient) Recv() (*OutputChunk, error) {
 }
 
 func (c *jobworkerServiceClient) StdErr(ctx context.Context, in *JobID, opts ...grpc.CallOption) (JobworkerService_StdErrClient, error) {
-	stream, err := c.cc.NewStream(ctx, &JobworkerService_ServiceDesc.Streams[1], "/overseer.JobworkerService/StdErr", opts...)
+	stream, err := c.cc.NewStream(ctx, &JobworkerService_ServiceDesc.Streams[3], "/overseer.JobworkerService/StdErr", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -124,15 +262,91 @@ func (x *jobworkerServiceStdErrClient) Recv() (*OutputChunk, error) {
 	return m, nil
 }
 
+func (c *jobworkerServiceClient) StdIn(ctx context.Context, opts ...grpc.CallOption) (JobworkerService_StdInClient, error) {
+	stream, err := c.cc.NewStream(ctx, &JobworkerService_ServiceDesc.Streams[4], "/overseer.JobworkerService/StdIn", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &jobworkerServiceStdInClient{stream}
+	return x, nil
+}
+
+type JobworkerService_StdInClient interface {
+	Send(*StdInChunk) error
+	CloseAndRecv() (*StdInResponse, error)
+	grpc.ClientStream
+}
+
+type jobworkerServiceStdInClient struct {
+	grpc.ClientStream
+}
+
+func (x *jobworkerServiceStdInClient) Send(m *StdInChunk) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *jobworkerServiceStdInClient) CloseAndRecv() (*StdInResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(StdInResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *jobworkerServiceClient) Attach(ctx context.Context, opts ...grpc.CallOption) (JobworkerService_AttachClient, error) {
+	stream, err := c.cc.NewStream(ctx, &JobworkerService_ServiceDesc.Streams[5], "/overseer.JobworkerService/Attach", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &jobworkerServiceAttachClient{stream}
+	return x, nil
+}
+
+type JobworkerService_AttachClient interface {
+	Send(*AttachRequest) error
+	Recv() (*AttachResponse, error)
+	grpc.ClientStream
+}
+
+type jobworkerServiceAttachClient struct {
+	grpc.ClientStream
+}
+
+func (x *jobworkerServiceAttachClient) Send(m *AttachRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *jobworkerServiceAttachClient) Recv() (*AttachResponse, error) {
+	m := new(AttachResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // JobworkerServiceServer is the server API for JobworkerService service.
 // All implementations must embed UnimplementedJobworkerServiceServer
 // for forward compatibility
 type JobworkerServiceServer interface {
 	Start(context.Context, *Job) (*JobID, error)
-	Stop(context.Context, *JobID) (*StopResponse, error)
+	Exec(context.Context, *ExecRequest) (*JobID, error)
+	Stop(context.Context, *StopRequest) (*StopResponse, error)
+	Signal(context.Context, *SignalRequest) (*SignalResponse, error)
+	Pause(context.Context, *JobID) (*PauseResponse, error)
+	Resume(context.Context, *JobID) (*ResumeResponse, error)
 	Status(context.Context, *JobID) (*StatusResponse, error)
+	Wait(context.Context, *JobID) (*StatusResponse, error)
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	Watch(*WatchRequest, JobworkerService_WatchServer) error
+	Stats(context.Context, *JobID) (*StatsResponse, error)
+	StreamStats(*StreamStatsRequest, JobworkerService_StreamStatsServer) error
 	StdOut(*JobID, JobworkerService_StdOutServer) error
 	StdErr(*JobID, JobworkerService_StdErrServer) error
+	StdIn(JobworkerService_StdInServer) error
+	Attach(JobworkerService_AttachServer) error
 	mustEmbedUnimplementedJobworkerServiceServer()
 }
 
@@ -143,18 +357,51 @@ type UnimplementedJobworkerServiceServer struct {
 func (UnimplementedJobworkerServiceServer) Start(context.Context, *Job) (*JobID, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Start not implemented")
 }
-func (UnimplementedJobworkerServiceServer) Stop(context.Context, *JobID) (*StopResponse, error) {
+func (UnimplementedJobworkerServiceServer) Exec(context.Context, *ExecRequest) (*JobID, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Exec not implemented")
+}
+func (UnimplementedJobworkerServiceServer) Stop(context.Context, *StopRequest) (*StopResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Stop not implemented")
 }
+func (UnimplementedJobworkerServiceServer) Signal(context.Context, *SignalRequest) (*SignalResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Signal not implemented")
+}
+func (UnimplementedJobworkerServiceServer) Pause(context.Context, *JobID) (*PauseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Pause not implemented")
+}
+func (UnimplementedJobworkerServiceServer) Resume(context.Context, *JobID) (*ResumeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Resume not implemented")
+}
 func (UnimplementedJobworkerServiceServer) Status(context.Context, *JobID) (*StatusResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
 }
+func (UnimplementedJobworkerServiceServer) Wait(context.Context, *JobID) (*StatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Wait not implemented")
+}
+func (UnimplementedJobworkerServiceServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedJobworkerServiceServer) Watch(*WatchRequest, JobworkerService_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedJobworkerServiceServer) Stats(context.Context, *JobID) (*StatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stats not implemented")
+}
+func (UnimplementedJobworkerServiceServer) StreamStats(*StreamStatsRequest, JobworkerService_StreamStatsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamStats not implemented")
+}
 func (UnimplementedJobworkerServiceServer) StdOut(*JobID, JobworkerService_StdOutServer) error {
 	return status.Errorf(codes.Unimplemented, "method StdOut not implemented")
 }
 func (UnimplementedJobworkerServiceServer) StdErr(*JobID, JobworkerService_StdErrServer) error {
 	return status.Errorf(codes.Unimplemented, "method StdErr not implemented")
 }
+func (UnimplementedJobworkerServiceServer) StdIn(JobworkerService_StdInServer) error {
+	return status.Errorf(codes.Unimplemented, "method StdIn not implemented")
+}
+func (UnimplementedJobworkerServiceServer) Attach(JobworkerService_AttachServer) error {
+	return status.Errorf(codes.Unimplemented, "method Attach not implemented")
+}
 func (UnimplementedJobworkerServiceServer) mustEmbedUnimplementedJobworkerServiceServer() {}
 
 // UnsafeJobworkerServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -186,8 +433,26 @@ func _JobworkerService_Start_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _JobworkerService_Exec_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExecRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobworkerServiceServer).Exec(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/overseer.JobworkerService/Exec",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobworkerServiceServer).Exec(ctx, req.(*ExecRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _JobworkerService_Stop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(JobID)
+	in := new(StopRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -199,7 +464,61 @@ func _JobworkerService_Stop_Handler(srv interface{}, ctx context.Context, dec fu
 		FullMethod: "/overseer.JobworkerService/Stop",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(JobworkerServiceServer).Stop(ctx, req.(*JobID))
+		return srv.(JobworkerServiceServer).Stop(ctx, req.(*StopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobworkerService_Signal_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignalRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobworkerServiceServer).Signal(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/overseer.JobworkerService/Signal",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobworkerServiceServer).Signal(ctx, req.(*SignalRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobworkerService_Pause_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobworkerServiceServer).Pause(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/overseer.JobworkerService/Pause",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobworkerServiceServer).Pause(ctx, req.(*JobID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobworkerService_Resume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobworkerServiceServer).Resume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/overseer.JobworkerService/Resume",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobworkerServiceServer).Resume(ctx, req.(*JobID))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -222,6 +541,102 @@ func _JobworkerService_Status_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _JobworkerService_Wait_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobworkerServiceServer).Wait(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/overseer.JobworkerService/Wait",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobworkerServiceServer).Wait(ctx, req.(*JobID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobworkerService_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobworkerServiceServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/overseer.JobworkerService/List",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobworkerServiceServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobworkerService_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(JobworkerServiceServer).Watch(m, &jobworkerServiceWatchServer{stream})
+}
+
+type JobworkerService_WatchServer interface {
+	Send(*JobEvent) error
+	grpc.ServerStream
+}
+
+type jobworkerServiceWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *jobworkerServiceWatchServer) Send(m *JobEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _JobworkerService_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobworkerServiceServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/overseer.JobworkerService/Stats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobworkerServiceServer).Stats(ctx, req.(*JobID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobworkerService_StreamStats_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamStatsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(JobworkerServiceServer).StreamStats(m, &jobworkerServiceStreamStatsServer{stream})
+}
+
+type JobworkerService_StreamStatsServer interface {
+	Send(*StatsResponse) error
+	grpc.ServerStream
+}
+
+type jobworkerServiceStreamStatsServer struct {
+	grpc.ServerStream
+}
+
+func (x *jobworkerServiceStreamStatsServer) Send(m *StatsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _JobworkerService_StdOut_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(JobID)
 	if err := stream.RecvMsg(m); err != nil {
@@ -264,6 +679,58 @@ func (x *jobworkerServiceStdErrServer) Send(m *OutputChunk) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _JobworkerService_StdIn_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(JobworkerServiceServer).StdIn(&jobworkerServiceStdInServer{stream})
+}
+
+type JobworkerService_StdInServer interface {
+	SendAndClose(*StdInResponse) error
+	Recv() (*StdInChunk, error)
+	grpc.ServerStream
+}
+
+type jobworkerServiceStdInServer struct {
+	grpc.ServerStream
+}
+
+func (x *jobworkerServiceStdInServer) SendAndClose(m *StdInResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *jobworkerServiceStdInServer) Recv() (*StdInChunk, error) {
+	m := new(StdInChunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _JobworkerService_Attach_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(JobworkerServiceServer).Attach(&jobworkerServiceAttachServer{stream})
+}
+
+type JobworkerService_AttachServer interface {
+	Send(*AttachResponse) error
+	Recv() (*AttachRequest, error)
+	grpc.ServerStream
+}
+
+type jobworkerServiceAttachServer struct {
+	grpc.ServerStream
+}
+
+func (x *jobworkerServiceAttachServer) Send(m *AttachResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *jobworkerServiceAttachServer) Recv() (*AttachRequest, error) {
+	m := new(AttachRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // JobworkerService_ServiceDesc is the grpc.ServiceDesc for JobworkerService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -275,16 +742,54 @@ var JobworkerService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Start",
 			Handler:    _JobworkerService_Start_Handler,
 		},
+		{
+			MethodName: "Exec",
+			Handler:    _JobworkerService_Exec_Handler,
+		},
 		{
 			MethodName: "Stop",
 			Handler:    _JobworkerService_Stop_Handler,
 		},
+		{
+			MethodName: "Signal",
+			Handler:    _JobworkerService_Signal_Handler,
+		},
+		{
+			MethodName: "Pause",
+			Handler:    _JobworkerService_Pause_Handler,
+		},
+		{
+			MethodName: "Resume",
+			Handler:    _JobworkerService_Resume_Handler,
+		},
 		{
 			MethodName: "Status",
 			Handler:    _JobworkerService_Status_Handler,
 		},
+		{
+			MethodName: "Wait",
+			Handler:    _JobworkerService_Wait_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _JobworkerService_List_Handler,
+		},
+		{
+			MethodName: "Stats",
+			Handler:    _JobworkerService_Stats_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _JobworkerService_Watch_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamStats",
+			Handler:       _JobworkerService_StreamStats_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "StdOut",
 			Handler:       _JobworkerService_StdOut_Handler,
@@ -295,6 +800,17 @@ var JobworkerService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _JobworkerService_StdErr_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "StdIn",
+			Handler:       _JobworkerService_StdIn_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Attach",
+			Handler:       _JobworkerService_Attach_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "api/overseer.proto",
 }
@@ -3,7 +3,6 @@ package server
 import (
 	"context"
 
-	"github.com/andres-teleport/overseer/api"
 	"github.com/andres-teleport/overseer/api/authentication"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -14,6 +13,12 @@ var (
 	ErrPermissionDenied = status.New(codes.PermissionDenied, "permission denied").Err()
 )
 
+// jobRequest is implemented by the requests targeting an existing job, they
+// carry the job ID in their id field
+type jobRequest interface {
+	GetId() string
+}
+
 type authorizationInterceptor struct {
 	parent *Server
 }
@@ -46,8 +51,8 @@ func (a *authorizationInterceptor) userJobAllowed(ctx context.Context, jobID str
 }
 
 func (a *authorizationInterceptor) unaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-	if r, ok := req.(*api.JobID); ok {
-		if err := a.userJobAllowed(ctx, r.Id); err != nil {
+	if r, ok := req.(jobRequest); ok {
+		if err := a.userJobAllowed(ctx, r.GetId()); err != nil {
 			return nil, err
 		}
 	}
@@ -64,10 +69,10 @@ func (ss *authorizationInterceptorServerStream) RecvMsg(m interface{}) error {
 		return err
 	}
 
-	jobID, ok := m.(*api.JobID)
+	r, ok := m.(jobRequest)
 	if !ok {
 		return nil
 	}
 
-	return ss.authInterceptor.userJobAllowed(ss.Context(), jobID.Id)
+	return ss.authInterceptor.userJobAllowed(ss.Context(), r.GetId())
 }
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"github.com/andres-teleport/overseer/api"
+	"github.com/andres-teleport/overseer/lib/multipipe"
+	"github.com/andres-teleport/overseer/lib/resourcecontrol"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrLimitExceeded     = status.Error(codes.InvalidArgument, "resource limits exceed the allowed maximum")
+	ErrRetentionExceeded = status.Error(codes.InvalidArgument, "the output retention keeps more than the one of the server")
+)
+
+func limitsFromAPI(l *api.ResourceLimits) resourcecontrol.ResourceLimits {
+	return resourcecontrol.ResourceLimits{
+		CPU:         resourcecontrol.MilliCPU(l.GetCpuMillis()),
+		CPUWeight:   l.GetCpuWeight(),
+		CPUSetCPUs:  l.GetCpusetCpus(),
+		CPUSetMems:  l.GetCpusetMems(),
+		Memory:      resourcecontrol.Bytes(l.GetMemoryBytes()),
+		MemoryHigh:  resourcecontrol.Bytes(l.GetMemoryHighBytes()),
+		SwapMax:     resourcecontrol.Bytes(l.GetSwapMaxBytes()),
+		NoSwap:      l.GetNoSwap(),
+		IOReadBps:   resourcecontrol.Bytes(l.GetIoReadBps()),
+		IOWriteBps:  resourcecontrol.Bytes(l.GetIoWriteBps()),
+		IOReadIOPS:  l.GetIoReadIops(),
+		IOWriteIOPS: l.GetIoWriteIops(),
+		IOWeight:    l.GetIoWeight(),
+		Pids:        l.GetPids(),
+	}
+}
+
+// limitFields returns pointers to every numeric limit, the weights are not
+// included as they are relative to the other jobs
+func limitFields(l *resourcecontrol.ResourceLimits) []*int64 {
+	return []*int64{
+		(*int64)(&l.CPU),
+		(*int64)(&l.Memory),
+		(*int64)(&l.MemoryHigh),
+		(*int64)(&l.SwapMax),
+		(*int64)(&l.IOReadBps),
+		(*int64)(&l.IOWriteBps),
+		&l.IOReadIOPS,
+		&l.IOWriteIOPS,
+		&l.Pids,
+	}
+}
+
+// resolveLimits validates the requested limits and fills the unset numeric ones
+// with the defaults, capped to the maximums. An error is returned if any of the
+// requested limits exceeds its maximum.
+func resolveLimits(l, defaults, max resourcecontrol.ResourceLimits) (resourcecontrol.ResourceLimits, error) {
+	if err := l.Validate(); err != nil {
+		return resourcecontrol.ResourceLimits{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ls, ds, ms := limitFields(&l), limitFields(&defaults), limitFields(&max)
+
+	for i := range ls {
+		requested, def, max := ls[i], *ds[i], *ms[i]
+
+		switch {
+		case *requested == 0:
+			*requested = def
+			if max > 0 && (def == 0 || def > max) {
+				*requested = max
+			}
+		case max > 0 && *requested > max:
+			return resourcecontrol.ResourceLimits{}, ErrLimitExceeded
+		}
+	}
+
+	if l.NoSwap {
+		l.SwapMax = 0
+	}
+
+	return l, nil
+}
+
+// resolveRetention validates the requested output retention, which is the given
+// default one if nil. An error is returned if it keeps more output than the
+// default one, when it is bounded.
+func resolveRetention(r *api.OutputRetention, def multipipe.Retention) (multipipe.Retention, error) {
+	if r == nil {
+		return def, nil
+	}
+
+	retention := multipipe.Retention{First: r.FirstBytes, Last: r.LastBytes, Limit: r.LimitBytes}
+	if err := retention.Validate(); err != nil {
+		return multipipe.Retention{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if max := def.Max(); max > 0 && (retention.Max() == 0 || retention.Max() > max) {
+		return multipipe.Retention{}, ErrRetentionExceeded
+	}
+
+	return retention, nil
+}
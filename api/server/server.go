@@ -4,22 +4,78 @@ import (
 	"context"
 	"io"
 	"net"
+	"path"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/andres-teleport/overseer/api"
 	"github.com/andres-teleport/overseer/api/authentication"
 	"github.com/andres-teleport/overseer/lib/multipipe"
+	"github.com/andres-teleport/overseer/lib/resourcecontrol"
 	"github.com/andres-teleport/overseer/lib/supervisor"
+	"golang.org/x/sys/unix"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultGracePeriod = 10 * time.Second
+	maxGracePeriod     = 5 * time.Minute
+
+	defaultListPageSize = 100
+	maxListPageSize     = 1000
+
+	defaultStatsInterval = time.Second
+	minStatsInterval     = 100 * time.Millisecond
+	maxStatsInterval     = time.Hour
 )
 
 var (
-	ErrEmptyCommand = status.Error(codes.InvalidArgument, "empty job command provided")
+	ErrEmptyCommand       = status.Error(codes.InvalidArgument, "empty job command provided")
+	ErrUnknownSignal      = status.Error(codes.InvalidArgument, "unknown signal")
+	ErrInvalidGracePeriod = status.Errorf(codes.InvalidArgument, "the grace period must be between 0 and %s", maxGracePeriod)
+	ErrInvalidPageSize    = status.Errorf(codes.InvalidArgument, "the page size must be between 0 and %d", maxListPageSize)
+	ErrEmptyLabelKey      = status.Error(codes.InvalidArgument, "label keys cannot be empty")
+	ErrUnknownStatus      = status.Error(codes.InvalidArgument, "unknown job status")
+	ErrRelativeCwd        = status.Error(codes.InvalidArgument, "the working directory must be an absolute path")
+	ErrStdInJobChanged    = status.Error(codes.InvalidArgument, "every chunk of the standard input must target the same job")
+	ErrAttachJobChanged   = status.Error(codes.InvalidArgument, "every attach request must target the same job")
+	ErrStdinWithTTY       = status.Error(codes.InvalidArgument, supervisor.ErrStdinWithTTY.Error())
+	ErrExecLimits         = status.Error(codes.InvalidArgument, "a process run in an existing job shares its resource limits")
+	ErrInvalidInterval    = status.Errorf(codes.InvalidArgument, "the interval must be between %s and %s", minStatsInterval, maxStatsInterval)
 )
 
+// Options holds the operator provided settings of the server
+type Options struct {
+	// MaxLimits are the highest resource limits a job can request, zero
+	// values mean that there is no maximum
+	MaxLimits resourcecontrol.ResourceLimits
+
+	// EnvPolicy selects the variables of the server inherited by the jobs
+	EnvPolicy resourcecontrol.EnvPolicy
+
+	// AlertThresholds are the percentages above which the alerts of the jobs
+	// are raised, zero values disable them
+	AlertThresholds supervisor.AlertThresholds
+
+	// StateDir is the directory where the jobs are recorded, so their status
+	// and owners survive restarts, they are not recorded if it is empty
+	StateDir string
+
+	// OutputDir is the directory where the output of the jobs is stored when
+	// they are not recorded, they keep it in memory if it is empty
+	OutputDir string
+
+	// OutputRetention selects the output of the jobs that is kept, unless they
+	// request another one that does not keep more
+	OutputRetention multipipe.Retention
+}
+
 type Server struct {
+	opts       Options
 	jobOwners  map[string]string
 	mu         *sync.RWMutex
 	supervisor *supervisor.Supervisor
@@ -28,17 +84,37 @@ type Server struct {
 	api.UnimplementedJobworkerServiceServer
 }
 
-func NewServer(listenAddr, keyFile, certFile, caFile string) (*Server, error) {
+func NewServer(listenAddr, keyFile, certFile, caFile string, opts Options) (*Server, error) {
 	creds, err := authentication.NewServerTransportCredentials(keyFile, certFile, caFile)
 	if err != nil {
 		return nil, err
 	}
 
 	s := &Server{
+		opts:       opts,
 		jobOwners:  make(map[string]string),
 		mu:         &sync.RWMutex{},
 		supervisor: supervisor.NewSupervisor(),
 	}
+	s.supervisor.AlertThresholds = opts.AlertThresholds
+	s.supervisor.OutputDir = opts.OutputDir
+
+	if opts.StateDir != "" {
+		// The jobs that are still running are recovered along with them
+		if s.supervisor, err = supervisor.NewSupervisorWithState(opts.StateDir, opts.AlertThresholds); err != nil {
+			return nil, err
+		}
+
+		// The owners of the jobs recorded before are kept along with them
+		jobs, _, err := s.supervisor.ListJobs(supervisor.JobFilter{}, "", 0)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, j := range jobs {
+			s.jobOwners[j.ID] = j.Owner
+		}
+	}
 
 	authInterceptor := NewAuthorizationInterceptor(s)
 
@@ -66,17 +142,71 @@ func (s *Server) Close() error {
 	return s.l.Close()
 }
 
+// validateJob checks the parts of a job description that are common to Start
+// and Exec
+func validateJob(job *api.Job) error {
+	if len(job.GetCommand()) == 0 {
+		return ErrEmptyCommand
+	}
+
+	if _, ok := job.Labels[""]; ok {
+		return ErrEmptyLabelKey
+	}
+
+	if err := resourcecontrol.ValidateEnv(job.Env); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if job.Cwd != "" && !path.IsAbs(job.Cwd) {
+		return ErrRelativeCwd
+	}
+
+	if job.Tty && job.Stdin {
+		return ErrStdinWithTTY
+	}
+
+	return nil
+}
+
+// jobSpec returns the spec of the given job, owned by the given user
+func (s *Server) jobSpec(job *api.Job, owner string, limits resourcecontrol.ResourceLimits, retention multipipe.Retention) supervisor.JobSpec {
+	return supervisor.JobSpec{
+		Command:   job.Command,
+		Arguments: job.Arguments,
+		Limits:    limits,
+		Labels:    job.Labels,
+		Owner:     owner,
+		EnvPolicy: s.opts.EnvPolicy,
+		Env:       job.Env,
+		Dir:       job.Cwd,
+		Stdin:     job.Stdin,
+		TTY:       job.Tty,
+
+		OutputRetention: retention,
+	}
+}
+
 func (s *Server) Start(ctx context.Context, job *api.Job) (*api.JobID, error) {
 	commonName, err := authentication.GetCommonNameFromCtx(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(job.Command) == 0 {
-		return nil, ErrEmptyCommand
+	if err := validateJob(job); err != nil {
+		return nil, err
+	}
+
+	limits, err := resolveLimits(limitsFromAPI(job.Limits), supervisor.DefaultLimits, s.opts.MaxLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	retention, err := resolveRetention(job.OutputRetention, s.opts.OutputRetention)
+	if err != nil {
+		return nil, err
 	}
 
-	jobID, err := s.supervisor.StartJob(job.Command, job.Arguments...)
+	jobID, err := s.supervisor.StartJobSpec(s.jobSpec(job, commonName, limits, retention))
 	if err != nil {
 		return nil, status.Error(codes.Aborted, err.Error())
 	}
@@ -90,15 +220,93 @@ func (s *Server) Start(ctx context.Context, job *api.Job) (*api.JobID, error) {
 	return resp, nil
 }
 
-func (s *Server) Stop(ctx context.Context, jobID *api.JobID) (*api.StopResponse, error) {
-	err := s.supervisor.StopJob(jobID.Id)
-	if err == supervisor.ErrJobFinished {
-		err = status.Error(codes.FailedPrecondition, err.Error())
-	} else if err != nil {
-		err = status.Error(codes.Internal, err.Error())
+// Exec starts a job in the cgroup of the running job with the given ID, owned
+// by the same user, which was checked by the authorization interceptor
+func (s *Server) Exec(ctx context.Context, req *api.ExecRequest) (*api.JobID, error) {
+	commonName, err := authentication.GetCommonNameFromCtx(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	return &api.StopResponse{}, err
+	if err := validateJob(req.Job); err != nil {
+		return nil, err
+	}
+
+	if limitsFromAPI(req.Job.Limits) != (resourcecontrol.ResourceLimits{}) {
+		return nil, ErrExecLimits
+	}
+
+	retention, err := resolveRetention(req.Job.OutputRetention, s.opts.OutputRetention)
+	if err != nil {
+		return nil, err
+	}
+
+	jobID, err := s.supervisor.ExecJob(req.Id, s.jobSpec(req.Job, commonName, resourcecontrol.ResourceLimits{}, retention))
+	switch err {
+	case nil:
+	case supervisor.ErrJobFinished, supervisor.ErrJobStopping, supervisor.ErrJobBusy, supervisor.ErrJobPaused:
+		return nil, jobStateError(err)
+	default:
+		return nil, status.Error(codes.Aborted, err.Error())
+	}
+
+	s.mu.Lock()
+	s.jobOwners[jobID] = commonName
+	s.mu.Unlock()
+
+	return &api.JobID{Id: jobID}, nil
+}
+
+func (s *Server) Stop(ctx context.Context, req *api.StopRequest) (*api.StopResponse, error) {
+	sig, grace := unix.SIGKILL, time.Duration(req.GracePeriodMillis)*time.Millisecond
+
+	if req.Signal != "" {
+		if sig = parseSignal(req.Signal); sig == 0 {
+			return nil, ErrUnknownSignal
+		}
+	}
+
+	if grace < 0 || grace > maxGracePeriod {
+		return nil, ErrInvalidGracePeriod
+	} else if grace == 0 {
+		grace = defaultGracePeriod
+	}
+
+	err := s.supervisor.StopJobGracefully(req.Id, sig, grace)
+
+	return &api.StopResponse{}, jobStateError(err)
+}
+
+func (s *Server) Signal(ctx context.Context, req *api.SignalRequest) (*api.SignalResponse, error) {
+	sig := parseSignal(req.Signal)
+	if sig == 0 {
+		return nil, ErrUnknownSignal
+	}
+
+	err := s.supervisor.SignalJob(req.Id, sig, req.Group)
+
+	return &api.SignalResponse{}, jobStateError(err)
+}
+
+// jobStateError converts the errors returned by the supervisor when a job is
+// not in the right state for an operation
+func jobStateError(err error) error {
+	switch err {
+	case nil:
+		return nil
+	case supervisor.ErrJobFinished, supervisor.ErrJobStopping, supervisor.ErrJobBusy, supervisor.ErrJobPaused, supervisor.ErrJobNotPaused, supervisor.ErrSharedCgroup:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
+
+func (s *Server) Pause(ctx context.Context, jobID *api.JobID) (*api.PauseResponse, error) {
+	return &api.PauseResponse{}, jobStateError(s.supervisor.PauseJob(jobID.Id))
+}
+
+func (s *Server) Resume(ctx context.Context, jobID *api.JobID) (*api.ResumeResponse, error) {
+	return &api.ResumeResponse{}, jobStateError(s.supervisor.ResumeJob(jobID.Id))
 }
 
 func (s *Server) Status(context context.Context, jobID *api.JobID) (*api.StatusResponse, error) {
@@ -107,53 +315,462 @@ func (s *Server) Status(context context.Context, jobID *api.JobID) (*api.StatusR
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	var status api.Status
+	return statusToAPI(st), nil
+}
 
-	switch st.Status {
-	case supervisor.StatusStarted:
-		status = api.Status_STARTED
-	case supervisor.StatusDone:
-		status = api.Status_DONE
-	case supervisor.StatusStopped:
-		status = api.Status_STOPPED
+// Wait blocks until the job has finished and returns its final status
+func (s *Server) Wait(ctx context.Context, jobID *api.JobID) (*api.StatusResponse, error) {
+	st, err := s.supervisor.WaitJob(ctx, jobID.Id)
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return nil, status.FromContextError(err).Err()
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	return &api.StatusResponse{
-		Status:   status,
-		ExitCode: int64(st.ExitCode),
-	}, nil
+	return statusToAPI(st), nil
 }
 
-func stream(jobID *api.JobID, srv grpc.ServerStream, sendFn func(*api.OutputChunk) error, fn func(string) (*multipipe.Reader, error)) error {
-	out, err := fn(jobID.Id)
+func (s *Server) List(ctx context.Context, req *api.ListRequest) (*api.ListResponse, error) {
+	commonName, err := authentication.GetCommonNameFromCtx(ctx)
 	if err != nil {
-		return status.Error(codes.Internal, err.Error())
+		return nil, err
+	}
+
+	pageSize := int(req.PageSize)
+	switch {
+	case pageSize < 0 || pageSize > maxListPageSize:
+		return nil, ErrInvalidPageSize
+	case pageSize == 0:
+		pageSize = defaultListPageSize
+	}
+
+	// Only the jobs of the caller are listed
+	filter := supervisor.JobFilter{
+		Owner:  commonName,
+		Labels: req.Labels,
+	}
+
+	for _, apiStatus := range req.Statuses {
+		st, ok := statusFromAPI[apiStatus]
+		if !ok {
+			return nil, ErrUnknownStatus
+		}
+		filter.Statuses = append(filter.Statuses, st)
+	}
+
+	jobs, next, err := s.supervisor.ListJobs(filter, req.PageToken, pageSize)
+	if err == supervisor.ErrInvalidPageToken {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &api.ListResponse{NextPageToken: next}
+	for _, j := range jobs {
+		resp.Jobs = append(resp.Jobs, &api.JobInfo{
+			Id:     j.ID,
+			Status: statusToAPI(j.Status),
+		})
+	}
+
+	return resp, nil
+}
+
+// Watch streams the lifecycle events of the given job, or of all the jobs of
+// the caller if no job ID is given. Watching a single job ends once it has
+// finished.
+func (s *Server) Watch(req *api.WatchRequest, stream api.JobworkerService_WatchServer) error {
+	ctx := stream.Context()
+
+	commonName, err := authentication.GetCommonNameFromCtx(ctx)
+	if err != nil {
+		return err
+	}
+
+	sub := s.supervisor.Subscribe()
+	defer sub.Close()
+
+	if req.JobId != "" {
+		st, err := s.supervisor.JobStatus(req.JobId)
+		if err != nil || st.Owner != commonName {
+			return ErrPermissionDenied
+		}
+
+		// The job may have finished before subscribing
+		if st.Status == supervisor.StatusDone || st.Status == supervisor.StatusStopped {
+			return nil
+		}
+	}
+
+	for {
+		var ev supervisor.Event
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, sub.Err().Error())
+			}
+			ev = e
+		}
+
+		if ev.Status.Owner != commonName || (req.JobId != "" && ev.JobID != req.JobId) {
+			continue
+		}
+
+		jobEvent := &api.JobEvent{
+			Type:   eventTypeToAPI[ev.Type],
+			Id:     ev.JobID,
+			Time:   timestamppb.New(ev.Time),
+			Status: statusToAPI(ev.Status),
+		}
+
+		if ev.Type == supervisor.EventAlert || ev.Type == supervisor.EventAlertCleared {
+			jobEvent.Alert = alertToAPI(ev.Alert)
+		}
+
+		if err := stream.Send(jobEvent); err != nil {
+			return err
+		}
+
+		if req.JobId != "" && (ev.Type == supervisor.EventExited || ev.Type == supervisor.EventStopped) {
+			return nil
+		}
+	}
+}
+
+var eventTypeToAPI = map[supervisor.EventType]api.EventType{
+	supervisor.EventStarted:      api.EventType_EVENT_STARTED,
+	supervisor.EventPaused:       api.EventType_EVENT_PAUSED,
+	supervisor.EventResumed:      api.EventType_EVENT_RESUMED,
+	supervisor.EventExited:       api.EventType_EVENT_EXITED,
+	supervisor.EventStopped:      api.EventType_EVENT_STOPPED,
+	supervisor.EventOOMKilled:    api.EventType_EVENT_OOM_KILLED,
+	supervisor.EventAlert:        api.EventType_EVENT_ALERT,
+	supervisor.EventAlertCleared: api.EventType_EVENT_ALERT_CLEARED,
+}
+
+var alertKindToAPI = map[supervisor.AlertKind]api.AlertKind{
+	supervisor.AlertCPUPressure:    api.AlertKind_ALERT_CPU_PRESSURE,
+	supervisor.AlertMemoryPressure: api.AlertKind_ALERT_MEMORY_PRESSURE,
+	supervisor.AlertIOPressure:     api.AlertKind_ALERT_IO_PRESSURE,
+	supervisor.AlertCPUThrottled:   api.AlertKind_ALERT_CPU_THROTTLED,
+	supervisor.AlertMemoryHigh:     api.AlertKind_ALERT_MEMORY_HIGH,
+	supervisor.AlertMemoryMax:      api.AlertKind_ALERT_MEMORY_MAX,
+	supervisor.AlertOOM:            api.AlertKind_ALERT_OOM,
+}
+
+func alertToAPI(a supervisor.Alert) *api.Alert {
+	resp := &api.Alert{
+		Kind:      alertKindToAPI[a.Kind],
+		Value:     a.Value,
+		Threshold: a.Threshold,
+		Start:     timestamppb.New(a.Start),
+	}
+
+	if !a.End.IsZero() {
+		resp.End = timestamppb.New(a.End)
+	}
+
+	return resp
+}
+
+var statusFromAPI = map[api.Status]int{
+	api.Status_STARTED: supervisor.StatusStarted,
+	api.Status_DONE:    supervisor.StatusDone,
+	api.Status_STOPPED: supervisor.StatusStopped,
+	api.Status_PAUSED:  supervisor.StatusPaused,
+}
+
+// Stats returns the current resource usage of the job with the given ID
+func (s *Server) Stats(ctx context.Context, jobID *api.JobID) (*api.StatsResponse, error) {
+	st, err := s.supervisor.JobStats(jobID.Id)
+	if err != nil {
+		return nil, jobStateError(err)
+	}
+
+	return statsToAPI(st), nil
+}
+
+// StreamStats sends the resource usage of the job every interval until it
+// finishes or the client goes away
+func (s *Server) StreamStats(req *api.StreamStatsRequest, stream api.JobworkerService_StreamStatsServer) error {
+	interval := time.Duration(req.IntervalMillis) * time.Millisecond
+	if interval == 0 {
+		interval = defaultStatsInterval
+	} else if interval < minStatsInterval || interval > maxStatsInterval {
+		return ErrInvalidInterval
 	}
 
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		st, err := s.supervisor.JobStats(req.Id)
+		if err == supervisor.ErrJobFinished {
+			return nil
+		} else if err != nil {
+			return jobStateError(err)
+		}
+
+		if err := stream.Send(statsToAPI(st)); err != nil {
+			return err
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// statsToAPI converts the resource usage of a job to its API representation
+func statsToAPI(st resourcecontrol.Stats) *api.StatsResponse {
+	resp := &api.StatsResponse{
+		Time: timestamppb.New(st.Time),
+		Cpu: &api.CPUStats{
+			UsageUsec:        st.CPU.UsageUsec,
+			UserUsec:         st.CPU.UserUsec,
+			SystemUsec:       st.CPU.SystemUsec,
+			Periods:          st.CPU.Periods,
+			ThrottledPeriods: st.CPU.ThrottledPeriods,
+			ThrottledUsec:    st.CPU.ThrottledUsec,
+		},
+		Memory: &api.MemoryStats{
+			CurrentBytes: st.Memory.Current,
+			PeakBytes:    st.Memory.Peak,
+			Stat:         st.Memory.Stat,
+		},
+		Pids: st.Pids,
+	}
+
+	for _, dev := range st.IO {
+		resp.Io = append(resp.Io, &api.IOStats{
+			Device:       dev.Device,
+			ReadBytes:    dev.ReadBytes,
+			WriteBytes:   dev.WriteBytes,
+			ReadOps:      dev.ReadOps,
+			WriteOps:     dev.WriteOps,
+			DiscardBytes: dev.DiscardBytes,
+			DiscardOps:   dev.DiscardOps,
+		})
+	}
+
+	return resp
+}
+
+// statusToAPI converts the status of a job to its API representation
+func statusToAPI(st supervisor.Status) *api.StatusResponse {
+	resp := &api.StatusResponse{
+		ExitCode:   int64(st.ExitCode),
+		CoreDumped: st.CoreDumped,
+		OomKilled:  st.OOMKilled,
+		Pid:        int64(st.PID),
+		Command:    st.Command,
+		Arguments:  st.Arguments,
+		Labels:     st.Labels,
+		StartTime:  timestamppb.New(st.StartTime),
+		ParentId:   st.ParentID,
+	}
+
+	for apiStatus, status := range statusFromAPI {
+		if status == st.Status {
+			resp.Status = apiStatus
+		}
+	}
+
+	if !st.EndTime.IsZero() {
+		resp.EndTime = timestamppb.New(st.EndTime)
+	}
+
+	if st.StopSignal != 0 {
+		resp.StopSignal = unix.SignalName(st.StopSignal)
+	}
+
+	if st.Signal != 0 {
+		resp.Signal = unix.SignalName(st.Signal)
+	}
+
+	for _, a := range st.Alerts {
+		resp.Alerts = append(resp.Alerts, alertToAPI(a))
+	}
+
+	return resp
+}
+
+// parseSignal returns the signal with the given name, with or without the SIG
+// prefix, or zero if it is unknown
+func parseSignal(name string) unix.Signal {
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+
+	return unix.SignalNum(name)
+}
+
+// stream sends the contents of the reader through sendFn until it is drained
+func stream(out *multipipe.Reader, sendFn func([]byte) error) error {
 	buf := make([]byte, 8192)
 	for eof := false; !eof; {
 		n, err := out.Read(buf)
 
+		// The contents read along with an error are sent first
+		if err := sendFn(buf[:n]); err != nil {
+			return err
+		}
+
 		if err == io.EOF {
 			eof = true
 		} else if err != nil {
 			return status.Error(codes.Aborted, err.Error())
 		}
-
-		if err := sendFn(&api.OutputChunk{
-			Output: buf[:n],
-		}); err != nil {
-			return err
-		}
 	}
 
 	return nil
 }
 
+// streamOutput sends the output of the job through the server stream
+func streamOutput(jobID *api.JobID, sendFn func(*api.OutputChunk) error, fn func(string) (*multipipe.Reader, error)) error {
+	out, err := fn(jobID.Id)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return stream(out, func(output []byte) error {
+		return sendFn(&api.OutputChunk{Output: output})
+	})
+}
+
 func (s *Server) StdOut(jobID *api.JobID, srv api.JobworkerService_StdOutServer) error {
-	return stream(jobID, srv, srv.Send, s.supervisor.JobStdOut)
+	return streamOutput(jobID, srv.Send, s.supervisor.JobStdOut)
 }
 
 func (s *Server) StdErr(jobID *api.JobID, srv api.JobworkerService_StdErrServer) error {
-	return stream(jobID, srv, srv.Send, s.supervisor.JobStdErr)
+	return streamOutput(jobID, srv.Send, s.supervisor.JobStdErr)
+}
+
+// StdIn writes the received chunks into the standard input of the job, the
+// authorization interceptor checks the job ID of every chunk
+func (s *Server) StdIn(srv api.JobworkerService_StdInServer) error {
+	var (
+		jobID string
+		stdin io.WriteCloser
+	)
+
+	for {
+		chunk, err := srv.Recv()
+		if err == io.EOF {
+			return srv.SendAndClose(&api.StdInResponse{})
+		} else if err != nil {
+			return err
+		}
+
+		if stdin == nil {
+			jobID = chunk.Id
+
+			stdin, err = s.supervisor.JobStdIn(jobID)
+			if err == supervisor.ErrNoStdin {
+				return status.Error(codes.FailedPrecondition, err.Error())
+			} else if err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+		} else if chunk.Id != jobID {
+			return ErrStdInJobChanged
+		}
+
+		// Writing fails once the job has finished or its input was closed
+		if _, err := stdin.Write(chunk.Input); err != nil {
+			return status.Error(codes.FailedPrecondition, err.Error())
+		}
+
+		if chunk.Close {
+			if err := stdin.Close(); err != nil {
+				return status.Error(codes.FailedPrecondition, err.Error())
+			}
+
+			return srv.SendAndClose(&api.StdInResponse{})
+		}
+	}
+}
+
+// Attach connects the stream to the terminal of a job until the client closes
+// its side, detaching from the job, or the job finishes. Only the output
+// produced while attached is sent, unless the first request asks for a replay.
+func (s *Server) Attach(srv api.JobworkerService_AttachServer) error {
+	req, err := srv.Recv()
+	if err != nil {
+		return err
+	}
+
+	term, out, err := s.supervisor.JobTerminal(req.Id, req.Replay)
+	if err == supervisor.ErrNoTTY {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	} else if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	// Closing the output wakes up its goroutine once the client detaches
+	defer out.Close()
+
+	outErr := make(chan error, 1)
+	go func() {
+		err := stream(out, func(output []byte) error {
+			return srv.Send(&api.AttachResponse{Output: output})
+		})
+
+		// The output ends with the error of the job if it failed, which is
+		// not an error of the attachment
+		if status.Code(err) == codes.Aborted {
+			err = nil
+		}
+		outErr <- err
+	}()
+
+	inErr := make(chan error, 1)
+	go func() {
+		inErr <- attachInput(srv, req, term)
+	}()
+
+	select {
+	case err = <-outErr:
+	case err = <-inErr:
+	}
+
+	return err
+}
+
+// attachInput writes the input and applies the window sizes received through
+// the stream to the terminal, starting with the given first request, until the
+// client closes its side of the stream
+func attachInput(srv api.JobworkerService_AttachServer, req *api.AttachRequest, term *resourcecontrol.Terminal) error {
+	jobID := req.Id
+
+	for {
+		if req.Id != jobID {
+			return ErrAttachJobChanged
+		}
+
+		if req.Resize != nil {
+			if err := term.Resize(uint16(req.Resize.Rows), uint16(req.Resize.Cols)); err != nil {
+				return status.Error(codes.FailedPrecondition, err.Error())
+			}
+		}
+
+		// Writing fails once the job has finished
+		if len(req.Input) > 0 {
+			if _, err := term.Write(req.Input); err != nil {
+				return status.Error(codes.FailedPrecondition, err.Error())
+			}
+		}
+
+		var err error
+		if req, err = srv.Recv(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
 }
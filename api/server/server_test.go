@@ -5,10 +5,14 @@ import (
 	"context"
 	"io"
 	"net"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/andres-teleport/overseer/api"
 	"github.com/andres-teleport/overseer/api/client"
+	"github.com/andres-teleport/overseer/lib/multipipe"
+	"github.com/andres-teleport/overseer/lib/resourcecontrol"
 	"github.com/andres-teleport/overseer/lib/supervisor"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -33,6 +37,7 @@ func newTestServer() (*Server, error) {
 		"test-assets/server.key",
 		"test-assets/server.crt",
 		"test-assets/ca.crt",
+		Options{},
 	)
 }
 
@@ -107,6 +112,14 @@ func TestActions(t *testing.T) {
 		t.Errorf("'%s' expected, '%s' got", expectedStatus, jobStatus.Status)
 	}
 
+	// Wait
+	jobStatus, err = cli.Wait(context.Background(), jobID)
+	assertNil(t, err)
+
+	if jobStatus.Status != expectedStatus || jobStatus.ExitCode != 0 {
+		t.Errorf("'%s = 0' expected, '%s = %d' got", expectedStatus, jobStatus.Status, jobStatus.ExitCode)
+	}
+
 	// Stop
 	err = cli.Stop(context.Background(), jobID)
 	st := status.Convert(err)
@@ -115,6 +128,194 @@ func TestActions(t *testing.T) {
 	}
 }
 
+func TestAttach(t *testing.T) {
+	srv, err := newTestServer()
+	assertNil(t, err)
+
+	go srv.Serve()
+	defer srv.Close()
+
+	cli, err := newKnownClient(getServerAddress(srv.l))
+	assertNil(t, err)
+
+	// Jobs without a terminal cannot be attached to
+	jobID, err := cli.Start(context.Background(), "true")
+	assertNil(t, err)
+
+	a, err := cli.Attach(context.Background(), jobID, 0, 0, false)
+	assertNil(t, err)
+
+	err = a.Output(io.Discard)
+	assertStatusCode(t, err, codes.FailedPrecondition)
+
+	// The output ends along with the job
+	jobID, err = cli.StartJob(context.Background(), &api.Job{
+		Command:   "sh",
+		Arguments: []string{"-c", "read l; echo got $l; stty size; exit 3"},
+		Tty:       true,
+	})
+	assertNil(t, err)
+
+	a, err = cli.Attach(context.Background(), jobID, 30, 100, true)
+	assertNil(t, err)
+
+	_, err = a.Write([]byte("hello\n"))
+	assertNil(t, err)
+
+	var out bytes.Buffer
+	assertNil(t, a.Output(&out))
+
+	expected := "hello\r\ngot hello\r\n30 100\r\n"
+	if out.String() != expected {
+		t.Errorf("'%s' expected, '%s' got", expected, out.String())
+	}
+}
+
+func TestExec(t *testing.T) {
+	srv, err := newTestServer()
+	assertNil(t, err)
+
+	go srv.Serve()
+	defer srv.Close()
+
+	cli, err := newKnownClient(getServerAddress(srv.l))
+	assertNil(t, err)
+
+	anotherCli, err := newAnotherKnownClient(getServerAddress(srv.l))
+	assertNil(t, err)
+
+	parentID, err := cli.Start(context.Background(), "sleep", "10")
+	assertNil(t, err)
+
+	// Only the owner of the job can run processes in it
+	_, err = anotherCli.Exec(context.Background(), parentID, &api.Job{Command: "echo"})
+	assertStatusCode(t, err, codes.PermissionDenied)
+
+	// The limits of the job are shared
+	_, err = cli.Exec(context.Background(), parentID, &api.Job{
+		Command: "echo",
+		Limits:  &api.ResourceLimits{MemoryBytes: 1 << 20},
+	})
+	assertStatusCode(t, err, codes.InvalidArgument)
+
+	testPhrase := "hello exec"
+	jobID, err := cli.Exec(context.Background(), parentID, &api.Job{Command: "echo", Arguments: []string{testPhrase}})
+	assertNil(t, err)
+
+	jobStatus, err := cli.Wait(context.Background(), jobID)
+	assertNil(t, err)
+
+	if jobStatus.ParentId != parentID {
+		t.Errorf("'%s' expected, '%s' got", parentID, jobStatus.ParentId)
+	}
+
+	rd, err := cli.StdOut(context.Background(), jobID)
+	assertNil(t, err)
+
+	out, err := io.ReadAll(rd)
+	assertNil(t, err)
+
+	out = bytes.TrimSpace(out)
+	if !bytes.Equal(out, []byte(testPhrase)) {
+		t.Errorf("'%s' expected, '%s' got", testPhrase, out)
+	}
+
+	// Nothing can be run in a finished job
+	assertNil(t, cli.Stop(context.Background(), parentID))
+
+	_, err = cli.Exec(context.Background(), parentID, &api.Job{Command: "echo"})
+	assertStatusCode(t, err, codes.FailedPrecondition)
+}
+
+func TestStats(t *testing.T) {
+	srv, err := newTestServer()
+	assertNil(t, err)
+
+	go srv.Serve()
+	defer srv.Close()
+
+	cli, err := newKnownClient(getServerAddress(srv.l))
+	assertNil(t, err)
+
+	jobID, err := cli.Start(context.Background(), "sleep", "10")
+	assertNil(t, err)
+
+	st, err := cli.Stats(context.Background(), jobID)
+	assertNil(t, err)
+
+	if st.Cpu.UsageUsec <= 0 {
+		t.Errorf("CPU usage expected, '%+v' got", st)
+	}
+
+	err = cli.StreamStats(context.Background(), jobID, time.Millisecond, func(*api.StatsResponse) error { return nil })
+	assertStatusCode(t, err, codes.InvalidArgument)
+
+	// The stream ends along with the job
+	samples := 0
+	err = cli.StreamStats(context.Background(), jobID, 100*time.Millisecond, func(*api.StatsResponse) error {
+		if samples++; samples == 2 {
+			return cli.Stop(context.Background(), jobID)
+		}
+		return nil
+	})
+	assertNil(t, err)
+
+	if samples < 2 {
+		t.Errorf("at least 2 samples expected, %d got", samples)
+	}
+
+	_, err = cli.Stats(context.Background(), jobID)
+	assertStatusCode(t, err, codes.FailedPrecondition)
+}
+
+func TestStateDir(t *testing.T) {
+	opts := Options{StateDir: t.TempDir()}
+
+	srv, err := NewServer("localhost:0", "test-assets/server.key", "test-assets/server.crt", "test-assets/ca.crt", opts)
+	assertNil(t, err)
+
+	go srv.Serve()
+
+	cli, err := newKnownClient(getServerAddress(srv.l))
+	assertNil(t, err)
+
+	jobID, err := cli.Start(context.Background(), "sh", "-c", "exit 3")
+	assertNil(t, err)
+
+	_, err = cli.Wait(context.Background(), jobID)
+	assertNil(t, err)
+	srv.Close()
+
+	// The job and its owner are loaded by the next server
+	srv, err = NewServer("localhost:0", "test-assets/server.key", "test-assets/server.crt", "test-assets/ca.crt", opts)
+	assertNil(t, err)
+
+	go srv.Serve()
+	defer srv.Close()
+
+	cli, err = newKnownClient(getServerAddress(srv.l))
+	assertNil(t, err)
+
+	anotherCli, err := newAnotherKnownClient(getServerAddress(srv.l))
+	assertNil(t, err)
+
+	jobStatus, err := cli.Status(context.Background(), jobID)
+	assertNil(t, err)
+
+	if jobStatus.Status != api.Status_DONE || jobStatus.ExitCode != 3 {
+		t.Errorf("'DONE = 3' expected, '%s = %d' got", jobStatus.Status, jobStatus.ExitCode)
+	}
+
+	_, err = anotherCli.Status(context.Background(), jobID)
+	assertStatusCode(t, err, codes.PermissionDenied)
+
+	jobs, err := cli.ListAll(context.Background(), &api.ListRequest{})
+	assertNil(t, err)
+	if len(jobs) != 1 || jobs[0].Id != jobID {
+		t.Errorf("'%s' expected, %v got", jobID, jobs)
+	}
+}
+
 func TestBadActions(t *testing.T) {
 	srv, err := newTestServer()
 	assertNil(t, err)
@@ -200,6 +401,20 @@ func TestAuthorization(t *testing.T) {
 	_, err = cli.Status(context.Background(), jobID)
 	assertNil(t, err)
 
+	// List (User B)
+	jobs, err := anotherCli.ListAll(context.Background(), &api.ListRequest{})
+	assertNil(t, err)
+	if len(jobs) != 0 {
+		t.Errorf("0 jobs expected, %d got", len(jobs))
+	}
+
+	// List (User A)
+	jobs, err = cli.ListAll(context.Background(), &api.ListRequest{})
+	assertNil(t, err)
+	if len(jobs) != 1 || jobs[0].Id != jobID {
+		t.Errorf("'%s' expected, %v got", jobID, jobs)
+	}
+
 	// StdOut (User B)
 	rd, err := anotherCli.StdOut(context.Background(), jobID)
 	assertNil(t, err)
@@ -258,3 +473,87 @@ func TestGetCommonNameFromCtx(t *testing.T) {
 		srv.mu.Unlock()
 	}
 }
+
+func TestResolveLimits(t *testing.T) {
+	defaults := resourcecontrol.ResourceLimits{CPU: 100, Memory: 1000, IOReadBps: 10, IOWriteBps: 10}
+	max := resourcecontrol.ResourceLimits{CPU: 500, Memory: 500, SwapMax: 100}
+
+	var tests = []struct {
+		requested resourcecontrol.ResourceLimits
+		expected  resourcecontrol.ResourceLimits
+		code      codes.Code
+	}{
+		{
+			resourcecontrol.ResourceLimits{},
+			resourcecontrol.ResourceLimits{CPU: 100, Memory: 500, SwapMax: 100, IOReadBps: 10, IOWriteBps: 10},
+			codes.OK,
+		},
+		{
+			resourcecontrol.ResourceLimits{CPU: 200, IOWriteBps: 99},
+			resourcecontrol.ResourceLimits{CPU: 200, Memory: 500, SwapMax: 100, IOReadBps: 10, IOWriteBps: 99},
+			codes.OK,
+		},
+		{
+			resourcecontrol.ResourceLimits{NoSwap: true, CPUWeight: 50, CPUSetCPUs: "0-1"},
+			resourcecontrol.ResourceLimits{CPU: 100, CPUWeight: 50, CPUSetCPUs: "0-1", Memory: 500, NoSwap: true, IOReadBps: 10, IOWriteBps: 10},
+			codes.OK,
+		},
+		{resourcecontrol.ResourceLimits{CPU: 501}, resourcecontrol.ResourceLimits{}, codes.InvalidArgument},
+		{resourcecontrol.ResourceLimits{CPU: 1}, resourcecontrol.ResourceLimits{}, codes.InvalidArgument},
+		{resourcecontrol.ResourceLimits{Memory: -1}, resourcecontrol.ResourceLimits{}, codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		limits, err := resolveLimits(tt.requested, defaults, max)
+		assertStatusCode(t, err, tt.code)
+		if limits != tt.expected {
+			t.Errorf("expected '%+v', got '%+v'", tt.expected, limits)
+		}
+	}
+}
+
+func TestResolveRetention(t *testing.T) {
+	def := multipipe.Retention{Last: 1000}
+
+	var tests = []struct {
+		requested *api.OutputRetention
+		expected  multipipe.Retention
+		code      codes.Code
+	}{
+		{nil, def, codes.OK},
+		{&api.OutputRetention{FirstBytes: 100, LastBytes: 900}, multipipe.Retention{First: 100, Last: 900}, codes.OK},
+		{&api.OutputRetention{LimitBytes: 1000}, multipipe.Retention{Limit: 1000}, codes.OK},
+		{&api.OutputRetention{}, multipipe.Retention{}, codes.InvalidArgument},
+		{&api.OutputRetention{LastBytes: 1001}, multipipe.Retention{}, codes.InvalidArgument},
+		{&api.OutputRetention{LastBytes: 10, LimitBytes: 10}, multipipe.Retention{}, codes.InvalidArgument},
+		{&api.OutputRetention{FirstBytes: -1}, multipipe.Retention{}, codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		retention, err := resolveRetention(tt.requested, def)
+		assertStatusCode(t, err, tt.code)
+		if retention != tt.expected {
+			t.Errorf("'%+v' expected, '%+v' got", tt.expected, retention)
+		}
+	}
+}
+
+func TestAlertToAPI(t *testing.T) {
+	start := time.Now()
+
+	for _, a := range []supervisor.Alert{
+		{Kind: supervisor.AlertCPUPressure, Value: 60, Threshold: 50, Start: start},
+		{Kind: supervisor.AlertOOM, Value: 1, Start: start, End: start.Add(time.Second)},
+	} {
+		resp := alertToAPI(a)
+		if resp.Kind.String() != "ALERT_"+strings.ReplaceAll(strings.ToUpper(a.Kind.String()), " ", "_") {
+			t.Errorf("'%s' expected, '%s' got", a.Kind, resp.Kind)
+		}
+		if resp.Value != a.Value || resp.Threshold != a.Threshold || !resp.Start.AsTime().Equal(a.Start) {
+			t.Errorf("'%+v' expected, '%+v' got", a, resp)
+		}
+		if (resp.End == nil) != a.End.IsZero() {
+			t.Errorf("'%v' end expected, '%v' got", a.End, resp.End)
+		}
+	}
+}
@@ -8,15 +8,48 @@ import (
 	"io"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/andres-teleport/overseer/api"
 	"github.com/andres-teleport/overseer/api/client"
+	"github.com/andres-teleport/overseer/lib/resourcecontrol"
+	"golang.org/x/sys/unix"
 )
 
 var (
 	errNoActionProvided = errors.New("no action was provided")
+	errNoSignalProvided = errors.New("a signal name must be provided, e.g. -signal JOB-ID SIGHUP")
+	errNoExecCommand    = errors.New("a command must be provided, e.g. -exec JOB-ID ps")
+	errInvalidKeyValue  = errors.New("expected a key=value pair")
 )
 
+// keyValueFlag is a flag.Value that accumulates key=value pairs, such as labels
+// or environment variables
+type keyValueFlag map[string]string
+
+func (l keyValueFlag) String() string {
+	var kvs []string
+	for k, v := range l {
+		kvs = append(kvs, k+"="+v)
+	}
+
+	return strings.Join(kvs, ",")
+}
+
+func (l keyValueFlag) Set(s string) error {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return errInvalidKeyValue
+	}
+
+	l[kv[0]] = kv[1]
+
+	return nil
+}
+
 func main() {
 	log.SetFlags(0)
 
@@ -27,15 +60,95 @@ func main() {
 	flag.StringVar(&cert, "cert", "certs/user.crt", "path to the certificate")
 	flag.StringVar(&ca, "ca", "certs/ca.crt", "path to the certificate of the Certificate Authority")
 
+	// Resource limits (used with -start)
+	var limits resourcecontrol.ResourceLimits
+	flag.Var(&limits.CPU, "cpu", "CPU limit, e.g. 500m, 0.5 or 50% (default: server defined)")
+	flag.Var(&limits.Memory, "mem", "memory limit, e.g. 128M (default: server defined)")
+	flag.Var(&limits.IOReadBps, "io-read", "IO read limit in bytes per second, e.g. 5M (default: server defined)")
+	flag.Var(&limits.IOWriteBps, "io-write", "IO write limit in bytes per second, e.g. 5M (default: server defined)")
+	flag.Int64Var(&limits.IOReadIOPS, "io-read-iops", 0, "IO read operations per second limit (default: server defined)")
+	flag.Int64Var(&limits.IOWriteIOPS, "io-write-iops", 0, "IO write operations per second limit (default: server defined)")
+	flag.Int64Var(&limits.IOWeight, "io-weight", 0, "relative IO weight, from 1 to 10000 (default: 100)")
+	flag.Int64Var(&limits.CPUWeight, "cpu-weight", 0, "relative CPU weight, from 1 to 10000 (default: 100)")
+	flag.StringVar(&limits.CPUSetCPUs, "cpuset-cpus", "", "CPUs the job can run on, e.g. 0-3,5 (default: all)")
+	flag.StringVar(&limits.CPUSetMems, "cpuset-mems", "", "memory nodes the job can use, e.g. 0 (default: all)")
+	flag.Var(&limits.MemoryHigh, "mem-high", "memory usage throttling threshold, e.g. 96M (default: none)")
+	flag.Var(&limits.SwapMax, "swap", "swap limit, e.g. 64M, 0 disables swap (default: server defined)")
+	flag.Int64Var(&limits.Pids, "pids", 0, "maximum number of processes (default: server defined)")
+
+	// Labels (set with -start, used as a filter with -list)
+	labels := keyValueFlag{}
+	flag.Var(labels, "label", "job label as key=value, can be repeated")
+
+	// Job environment (used with -start and -run)
+	env := keyValueFlag{}
+	var cwd string
+	flag.Var(env, "env", "environment variable of the job as KEY=VALUE, can be repeated")
+	flag.StringVar(&cwd, "cwd", "", "absolute path of the working directory of the job (default: server defined)")
+
+	// Terminal (used with -start and -run)
+	var tty bool
+	flag.BoolVar(&tty, "tty", false, "run the job with a pseudo-terminal, -run attaches to it")
+
+	// Output retention (used with -start, -run and -exec)
+	var keepFirst, keepLast, outputLimit resourcecontrol.Bytes
+	flag.Var(&keepFirst, "keep-first", "amount of the first output of the job kept, dropping the output between it and -keep-last, e.g. 1M (default: server defined)")
+	flag.Var(&keepLast, "keep-last", "amount of the last output of the job kept, dropping the older output, e.g. 10M (default: server defined)")
+	flag.Var(&outputLimit, "output-limit", "amount of output of the job kept, the job is stopped once its output goes past it, e.g. 100M (default: server defined)")
+
+	// List options (used with -list)
+	var filterStatus string
+	flag.StringVar(&filterStatus, "filter-status", "", "comma separated statuses of the jobs to list, e.g. STARTED,PAUSED (default: all)")
+
+	// Stop options (used with -stop)
+	var stopSignal string
+	var gracePeriod time.Duration
+	flag.StringVar(&stopSignal, "stop-signal", "", "signal sent to the job before killing it, e.g. SIGTERM (default: kill immediately)")
+	flag.DurationVar(&gracePeriod, "grace", 0, "time to wait for the job to exit after the stop signal before killing it (default: server defined)")
+
+	// Stats options (used with -stats)
+	var statsInterval time.Duration
+	flag.DurationVar(&statsInterval, "stats-interval", 0, "print the resource usage every interval until the job finishes, e.g. 1s (default: print it once)")
+
+	// Signal options (used with -signal)
+	var signalGroup bool
+	flag.BoolVar(&signalGroup, "group", false, "send the signal to every process of the job instead of only the main one")
+
 	// Action flags
-	var startCmd, stopJobID, statusJobID, stdOutJobID, stdErrJobID string
+	var startCmd, runCmd, execJobID, stopJobID, signalJobID, pauseJobID, resumeJobID, statusJobID, statsJobID, attachJobID, stdOutJobID, stdErrJobID string
 	flag.StringVar(&startCmd, "start", "", "description")
+	flag.StringVar(&runCmd, "run", "", "start the job, print its output until it finishes and exit with its exit code")
+	flag.StringVar(&execJobID, "exec", "", "run the command given as argument in the cgroup of the job, like -run")
 	flag.StringVar(&stopJobID, "stop", "", "description")
+	flag.StringVar(&signalJobID, "signal", "", "send the signal given as argument (e.g. SIGHUP) to the job")
+	flag.StringVar(&pauseJobID, "pause", "", "freeze the processes of the job")
+	flag.StringVar(&resumeJobID, "resume", "", "resume the processes of a paused job")
 	flag.StringVar(&statusJobID, "status", "", "description")
+	flag.StringVar(&statsJobID, "stats", "", "print the resource usage of the job")
+	flag.StringVar(&attachJobID, "attach", "", "connect to the terminal of a job started with -tty, detach with Ctrl-P Ctrl-Q")
 	flag.StringVar(&stdOutJobID, "stdout", "", "description")
 	flag.StringVar(&stdErrJobID, "stderr", "", "description")
+	var list, watch bool
+	flag.BoolVar(&list, "list", false, "list the jobs along with their status")
+	flag.BoolVar(&watch, "watch", false, "print the lifecycle events of the job given as argument, or of all the jobs")
 	flag.Parse()
 
+	// An explicit "-swap 0" disables the swap instead of using the default,
+	// the retention of the server is used unless one is given
+	var retention *api.OutputRetention
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "swap":
+			limits.NoSwap = limits.SwapMax == 0
+		case "keep-first", "keep-last", "output-limit":
+			retention = &api.OutputRetention{
+				FirstBytes: int64(keepFirst),
+				LastBytes:  int64(keepLast),
+				LimitBytes: int64(outputLimit),
+			}
+		}
+	})
+
 	// TODO: return error if more than one action is supplied
 
 	cli, err := client.NewClient(server, key, cert, ca)
@@ -48,20 +161,79 @@ func main() {
 	switch {
 	case len(startCmd) > 0:
 		var jobID string
-		if jobID, err = cli.Start(ctx, startCmd, flag.Args()...); err == nil {
+		if jobID, err = cli.StartJob(ctx, newJob(startCmd, flag.Args(), limits, labels, env, cwd, tty, retention)); err == nil {
 			fmt.Println(jobID)
 		}
+	case len(runCmd) > 0:
+		var exitCode int
+		if exitCode, err = runJob(ctx, cli, newJob(runCmd, flag.Args(), limits, labels, env, cwd, tty, retention), ""); err == nil {
+			os.Exit(exitCode)
+		}
+	case len(execJobID) > 0:
+		if flag.NArg() == 0 {
+			err = errNoExecCommand
+			break
+		}
+
+		var exitCode int
+		if exitCode, err = runJob(ctx, cli, newJob(flag.Arg(0), flag.Args()[1:], limits, labels, env, cwd, tty, retention), execJobID); err == nil {
+			os.Exit(exitCode)
+		}
 	case len(stopJobID) > 0:
-		err = cli.Stop(ctx, stopJobID)
+		err = cli.StopGracefully(ctx, stopJobID, stopSignal, gracePeriod)
+	case len(signalJobID) > 0:
+		if flag.NArg() != 1 {
+			err = errNoSignalProvided
+		} else {
+			err = cli.Signal(ctx, signalJobID, flag.Arg(0), signalGroup)
+		}
+	case len(pauseJobID) > 0:
+		err = cli.Pause(ctx, pauseJobID)
+	case len(resumeJobID) > 0:
+		err = cli.Resume(ctx, resumeJobID)
 	case len(statusJobID) > 0:
 		var status *api.StatusResponse
 		if status, err = cli.Status(ctx, statusJobID); err == nil {
-			if status.Status != api.Status_STARTED {
-				fmt.Println(status.Status, "=", status.ExitCode)
-			} else {
-				fmt.Println(status.Status)
-			}
+			printStatus(status)
+		}
+	case len(statsJobID) > 0 && statsInterval > 0:
+		var prev *api.StatsResponse
+		fmt.Printf(statsRowFormat, "TIME", "CPU", "MEMORY", "PIDS", "IO READ", "IO WRITE")
+		err = cli.StreamStats(ctx, statsJobID, statsInterval, func(st *api.StatsResponse) error {
+			printStatsRow(prev, st)
+			prev = st
+			return nil
+		})
+	case len(statsJobID) > 0:
+		var st *api.StatsResponse
+		if st, err = cli.Stats(ctx, statsJobID); err == nil {
+			printStats(st)
+		}
+	case list:
+		req := &api.ListRequest{Labels: labels}
+		if req.Statuses, err = parseStatuses(filterStatus); err != nil {
+			break
 		}
+
+		var jobs []*api.JobInfo
+		if jobs, err = cli.ListAll(ctx, req); err == nil {
+			printJobs(jobs)
+		}
+	case len(attachJobID) > 0:
+		_, err = attachJob(ctx, cli, attachJobID, false)
+	case watch:
+		err = cli.Watch(ctx, flag.Arg(0), func(ev *api.JobEvent) error {
+			details := formatState(ev.Status)
+			if ev.Alert != nil {
+				details = formatAlert(ev.Alert)
+			}
+
+			fmt.Println(
+				ev.Time.AsTime().Local().Format(time.RFC3339), ev.Id,
+				strings.TrimPrefix(ev.Type.String(), "EVENT_"), details,
+			)
+			return nil
+		})
 	case len(stdOutJobID) > 0:
 		var rd *io.PipeReader
 		rd, err = cli.StdOut(ctx, stdOutJobID)
@@ -84,3 +256,320 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// printStatus prints the state of a job, e.g.
+// "STOPPED = -1 (signal SIGKILL, stop signal SIGTERM)", followed by a summary of
+// its command, PID and run times
+func printStatus(status *api.StatusResponse) {
+	fmt.Println(formatState(status))
+
+	startTime := status.StartTime.AsTime()
+	duration := time.Since(startTime)
+	if status.EndTime != nil {
+		duration = status.EndTime.AsTime().Sub(startTime)
+	}
+
+	fmt.Println("Command: ", strings.Join(append([]string{status.Command}, status.Arguments...), " "))
+	fmt.Println("PID:     ", status.Pid)
+	if status.ParentId != "" {
+		fmt.Println("Parent:  ", status.ParentId)
+	}
+	if len(status.Labels) > 0 {
+		fmt.Println("Labels:  ", keyValueFlag(status.Labels))
+	}
+	fmt.Println("Started: ", startTime.Local().Format(time.RFC3339))
+	if status.EndTime != nil {
+		fmt.Println("Finished:", status.EndTime.AsTime().Local().Format(time.RFC3339))
+	}
+	fmt.Println("Duration:", duration.Round(time.Millisecond))
+
+	for i, a := range status.Alerts {
+		period := a.Start.AsTime().Local().Format(time.RFC3339) + " - "
+		if a.End != nil {
+			period += a.End.AsTime().Local().Format(time.RFC3339)
+		} else {
+			period += "active"
+		}
+
+		title := "         "
+		if i == 0 {
+			title = "Alerts:  "
+		}
+		fmt.Println(title, formatAlert(a), period)
+	}
+}
+
+// formatAlert returns the kind of an alert along with the value that crossed
+// its threshold, e.g. "CPU_PRESSURE 63.20 > 50.00"
+func formatAlert(a *api.Alert) string {
+	return fmt.Sprintf("%s %.2f > %.2f", strings.TrimPrefix(a.Kind.String(), "ALERT_"), a.Value, a.Threshold)
+}
+
+// formatState returns the state of a job and, once it has finished, its exit
+// code and the details of its termination
+func formatState(status *api.StatusResponse) string {
+	if status.Status == api.Status_STARTED || status.Status == api.Status_PAUSED {
+		return status.Status.String()
+	}
+
+	var details []string
+	if status.Signal != "" {
+		details = append(details, "signal "+status.Signal)
+	}
+	if status.StopSignal != "" {
+		details = append(details, "stop signal "+status.StopSignal)
+	}
+	if status.CoreDumped {
+		details = append(details, "core dumped")
+	}
+	if status.OomKilled {
+		details = append(details, "OOM killed")
+	}
+
+	state := fmt.Sprintf("%s = %d", status.Status, status.ExitCode)
+	if len(details) > 0 {
+		state += " (" + strings.Join(details, ", ") + ")"
+	}
+
+	return state
+}
+
+// newJob returns the description of a job with the given command, arguments,
+// resource limits, labels, environment, working directory and whether it has a
+// terminal
+func newJob(command string, args []string, limits resourcecontrol.ResourceLimits, labels, env keyValueFlag, cwd string, tty bool, retention *api.OutputRetention) *api.Job {
+	return &api.Job{
+		Command:   command,
+		Arguments: args,
+		Limits: &api.ResourceLimits{
+			CpuMillis:       int64(limits.CPU),
+			CpuWeight:       limits.CPUWeight,
+			CpusetCpus:      limits.CPUSetCPUs,
+			CpusetMems:      limits.CPUSetMems,
+			MemoryBytes:     int64(limits.Memory),
+			MemoryHighBytes: int64(limits.MemoryHigh),
+			SwapMaxBytes:    int64(limits.SwapMax),
+			NoSwap:          limits.NoSwap,
+			IoReadBps:       int64(limits.IOReadBps),
+			IoWriteBps:      int64(limits.IOWriteBps),
+			IoReadIops:      limits.IOReadIOPS,
+			IoWriteIops:     limits.IOWriteIOPS,
+			IoWeight:        limits.IOWeight,
+			Pids:            limits.Pids,
+		},
+		Labels: labels,
+		Env:    env,
+		Cwd:    cwd,
+		Tty:    tty,
+
+		OutputRetention: retention,
+	}
+}
+
+// runJob starts the job, in the cgroup of the job given by parentID if not
+// empty, forwards the local standard input to it and copies its output streams
+// to the local ones until it finishes, then returns its exit code. Jobs with a
+// terminal are attached to instead, and detaching from them exits right away.
+// Jobs terminated by a signal get the exit code of a shell, 128 plus the signal
+// number.
+func runJob(ctx context.Context, cli *client.Client, job *api.Job, parentID string) (int, error) {
+	job.Stdin = !job.Tty
+
+	var jobID string
+	var err error
+	if parentID != "" {
+		jobID, err = cli.Exec(ctx, parentID, job)
+	} else {
+		jobID, err = cli.StartJob(ctx, job)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if job.Tty {
+		// The job might have written something before attaching
+		if detached, err := attachJob(ctx, cli, jobID, true); err != nil {
+			return 0, err
+		} else if detached {
+			fmt.Fprintln(os.Stderr, "detached from job", jobID)
+			return 0, nil
+		}
+	} else if err := forwardStreams(ctx, cli, jobID); err != nil {
+		return 0, err
+	}
+
+	status, err := cli.Wait(ctx, jobID)
+	switch {
+	case err != nil:
+		return 0, err
+	case status.Signal != "":
+		return 128 + int(unix.SignalNum(status.Signal)), nil
+	case status.ExitCode < 0:
+		return 1, nil
+	}
+
+	return int(status.ExitCode), nil
+}
+
+// forwardStreams forwards the local standard input to the job and copies its
+// output streams to the local ones until it finishes
+func forwardStreams(ctx context.Context, cli *client.Client, jobID string) error {
+	// The job may finish without reading all of its input, so the errors are
+	// ignored
+	go func() {
+		_ = cli.StdIn(ctx, jobID, os.Stdin)
+	}()
+
+	stdout, err := cli.StdOut(ctx, jobID)
+	if err != nil {
+		return err
+	}
+
+	stderr, err := cli.StdErr(ctx, jobID)
+	if err != nil {
+		return err
+	}
+
+	errs := make(chan error, 2)
+	for _, c := range []struct {
+		w  io.Writer
+		rd *io.PipeReader
+	}{{os.Stdout, stdout}, {os.Stderr, stderr}} {
+		go func(w io.Writer, rd *io.PipeReader) {
+			_, err := io.Copy(w, rd)
+			errs <- err
+		}(c.w, c.rd)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseStatuses parses a comma separated list of statuses, e.g. "DONE,STOPPED"
+func parseStatuses(list string) ([]api.Status, error) {
+	var statuses []api.Status
+
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.ToUpper(strings.TrimSpace(name)); name == "" {
+			continue
+		}
+
+		st, ok := api.Status_value[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown status %q", name)
+		}
+		statuses = append(statuses, api.Status(st))
+	}
+
+	return statuses, nil
+}
+
+// printJobs prints the given jobs as a table
+func printJobs(jobs []*api.JobInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tEXIT CODE\tSTARTED\tDURATION\tCOMMAND")
+
+	for _, j := range jobs {
+		st := j.Status
+		startTime := st.StartTime.AsTime()
+
+		exitCode, duration := "-", time.Since(startTime)
+		if st.EndTime != nil {
+			exitCode = strconv.FormatInt(st.ExitCode, 10)
+			duration = st.EndTime.AsTime().Sub(startTime)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			j.Id, st.Status, exitCode,
+			startTime.Local().Format(time.RFC3339),
+			duration.Round(time.Second),
+			strings.Join(append([]string{st.Command}, st.Arguments...), " "),
+		)
+	}
+
+	w.Flush()
+}
+
+// formatBytes returns the given amount of bytes in a human readable form, e.g.
+// "1.5 MiB"
+func formatBytes(n int64) string {
+	const units = "KMGTPE"
+
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	v, i := float64(n)/1024, 0
+	for ; v >= 1024 && i < len(units)-1; i++ {
+		v /= 1024
+	}
+
+	return fmt.Sprintf("%.1f %ciB", v, units[i])
+}
+
+// ioTotals returns the bytes read and written by a job across all devices
+func ioTotals(st *api.StatsResponse) (read, write int64) {
+	for _, dev := range st.Io {
+		read += dev.ReadBytes
+		write += dev.WriteBytes
+	}
+
+	return
+}
+
+// printStats prints the resource usage of a job, the lines of the controllers
+// that are not enabled for it are left out
+func printStats(st *api.StatsResponse) {
+	usec := func(v int64) time.Duration { return time.Duration(v) * time.Microsecond }
+
+	fmt.Printf("CPU:       %s (user %s, system %s)\n", usec(st.Cpu.UsageUsec), usec(st.Cpu.UserUsec), usec(st.Cpu.SystemUsec))
+	if st.Cpu.Periods > 0 {
+		fmt.Printf("Throttled: %d of %d periods, %s\n", st.Cpu.ThrottledPeriods, st.Cpu.Periods, usec(st.Cpu.ThrottledUsec))
+	}
+
+	if st.Memory.Stat != nil {
+		fmt.Printf("Memory:    %s (peak %s, anon %s, file %s, kernel %s)\n",
+			formatBytes(st.Memory.CurrentBytes), formatBytes(st.Memory.PeakBytes),
+			formatBytes(st.Memory.Stat["anon"]), formatBytes(st.Memory.Stat["file"]), formatBytes(st.Memory.Stat["kernel"]),
+		)
+	}
+
+	for _, dev := range st.Io {
+		fmt.Printf("%-11sread %s (%d ops), write %s (%d ops)\n",
+			"IO "+dev.Device+":",
+			formatBytes(dev.ReadBytes), dev.ReadOps, formatBytes(dev.WriteBytes), dev.WriteOps,
+		)
+	}
+
+	// A job has at least one process, so zero means no pids controller
+	if st.Pids > 0 {
+		fmt.Println("PIDs:     ", st.Pids)
+	}
+}
+
+const statsRowFormat = "%-25s  %6s  %10s  %5s  %10s  %10s\n"
+
+// printStatsRow prints the resource usage of a job as a table row, the CPU
+// usage is the percentage of a single CPU used since the previous sample
+func printStatsRow(prev, st *api.StatsResponse) {
+	cpu := "-"
+	if prev != nil {
+		elapsed := st.Time.AsTime().Sub(prev.Time.AsTime())
+		used := time.Duration(st.Cpu.UsageUsec-prev.Cpu.UsageUsec) * time.Microsecond
+		if elapsed > 0 {
+			cpu = fmt.Sprintf("%.1f%%", 100*float64(used)/float64(elapsed))
+		}
+	}
+
+	read, write := ioTotals(st)
+	fmt.Printf(statsRowFormat,
+		st.Time.AsTime().Local().Format(time.RFC3339), cpu,
+		formatBytes(st.Memory.CurrentBytes), strconv.FormatInt(st.Pids, 10),
+		formatBytes(read), formatBytes(write),
+	)
+}
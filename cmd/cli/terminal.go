@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/andres-teleport/overseer/api/client"
+	"golang.org/x/sys/unix"
+)
+
+// detachKeys is the key sequence that detaches from a job, Ctrl-P Ctrl-Q
+var detachKeys = []byte{0x10, 0x11}
+
+// makeRaw puts the terminal into raw mode, so every key press is sent to the
+// job as is, and returns a function restoring its previous state
+func makeRaw(fd int) (func(), error) {
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, err
+	}
+	saved := *termios
+
+	termios.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	termios.Oflag &^= unix.OPOST
+	termios.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	termios.Cflag &^= unix.CSIZE | unix.PARENB
+	termios.Cflag |= unix.CS8
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, termios); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		_ = unix.IoctlSetTermios(fd, unix.TCSETS, &saved)
+	}, nil
+}
+
+// attachJob connects the local terminal to the one of the job until the job
+// finishes or the detach keys are pressed, returning true in the latter case.
+// The output produced before attaching is only printed if replay is true.
+func attachJob(ctx context.Context, cli *client.Client, jobID string, replay bool) (bool, error) {
+	fd := int(os.Stdin.Fd())
+
+	// The local standard input might not be a terminal, e.g. a pipe
+	var rows, cols uint16
+	if ws, err := unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ); err == nil {
+		rows, cols = ws.Row, ws.Col
+
+		restore, err := makeRaw(fd)
+		if err != nil {
+			return false, err
+		}
+		defer restore()
+	}
+
+	a, err := cli.Attach(ctx, jobID, rows, cols, replay)
+	if err != nil {
+		return false, err
+	}
+
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, unix.SIGWINCH)
+	defer signal.Stop(winch)
+
+	go func() {
+		for range winch {
+			if ws, err := unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ); err == nil {
+				_ = a.Resize(ws.Row, ws.Col)
+			}
+		}
+	}()
+
+	detached := make(chan struct{})
+	go func() {
+		// Sending fails once the job has finished, which ends the output too,
+		// and the output is still printed once the local input has ended
+		if err := forwardInput(a, os.Stdin); err == nil {
+			close(detached)
+			_ = a.Detach()
+		}
+	}()
+
+	if err := a.Output(os.Stdout); err != nil {
+		return false, err
+	}
+
+	select {
+	case <-detached:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// forwardInput sends the input read from r to the job until the detach keys
+// are found, returning nil, or reading or sending fails
+func forwardInput(a *client.Attachment, r io.Reader) error {
+	buf := make([]byte, 1024)
+
+	// pending is set when a chunk ended with the first of the detach keys
+	pending := false
+
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return err
+		}
+
+		in := buf[:n]
+		if pending {
+			pending = false
+
+			if len(in) > 0 && in[0] == detachKeys[1] {
+				return nil
+			}
+			in = append(detachKeys[:1:1], in...)
+		}
+
+		if i := bytes.Index(in, detachKeys); i >= 0 {
+			if i > 0 {
+				_, err = a.Write(in[:i])
+			}
+			return err
+		} else if len(in) > 0 && in[len(in)-1] == detachKeys[0] {
+			pending, in = true, in[:len(in)-1]
+		}
+
+		if len(in) == 0 {
+			continue
+		}
+
+		if _, err := a.Write(in); err != nil {
+			return err
+		}
+	}
+}
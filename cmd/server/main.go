@@ -1,11 +1,17 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/andres-teleport/overseer/api/server"
+	"github.com/andres-teleport/overseer/lib/multipipe"
+	"github.com/andres-teleport/overseer/lib/resourcecontrol"
+	"github.com/andres-teleport/overseer/lib/supervisor"
 )
 
 func main() {
@@ -16,11 +22,78 @@ func main() {
 	flag.StringVar(&key, "key", "certs/server.key", "path to the private key")
 	flag.StringVar(&cert, "cert", "certs/server.crt", "path to the certificate")
 	flag.StringVar(&ca, "ca", "certs/ca.crt", "path to the certificate of the Certificate Authority")
+
+	// Resource limits
+	opts := server.Options{
+		MaxLimits: resourcecontrol.ResourceLimits{
+			CPU:    1000,
+			Memory: 1 << 30,
+		},
+	}
+	flag.Var(&opts.MaxLimits.CPU, "max-cpu", "maximum CPU a job can request, e.g. 500m, 0.5 or 50% (0 for no maximum)")
+	flag.Var(&opts.MaxLimits.Memory, "max-mem", "maximum memory a job can request, e.g. 512M (0 for no maximum)")
+	flag.Var(&opts.MaxLimits.IOReadBps, "max-io-read", "maximum IO read rate a job can request, in bytes per second, e.g. 10M (0 for no maximum)")
+	flag.Var(&opts.MaxLimits.IOWriteBps, "max-io-write", "maximum IO write rate a job can request, in bytes per second, e.g. 10M (0 for no maximum)")
+	flag.Int64Var(&opts.MaxLimits.IOReadIOPS, "max-io-read-iops", 0, "maximum IO read operations per second a job can request (0 for no maximum)")
+	flag.Int64Var(&opts.MaxLimits.IOWriteIOPS, "max-io-write-iops", 0, "maximum IO write operations per second a job can request (0 for no maximum)")
+	flag.Var(&opts.MaxLimits.SwapMax, "max-swap", "maximum swap a job can request, e.g. 512M (0 for no maximum)")
+	flag.Int64Var(&opts.MaxLimits.Pids, "max-pids", 0, "maximum number of processes a job can request (0 for no maximum)")
+
+	// Job environment
+	var inheritEnv string
+	flag.StringVar(&inheritEnv, "inherit-env", "", "comma separated names of the environment variables of the server passed to the jobs, e.g. LANG,TZ")
+	flag.StringVar(&opts.EnvPolicy.Path, "job-path", resourcecontrol.SafePath, "PATH of the jobs")
+
+	// Alerts
+	opts.AlertThresholds = supervisor.DefaultAlertThresholds
+	flag.Float64Var(&opts.AlertThresholds.CPUPressure, "alert-cpu-pressure", opts.AlertThresholds.CPUPressure, "percentage of time the processes of a job wait for CPU above which an alert is raised (0 to disable)")
+	flag.Float64Var(&opts.AlertThresholds.MemoryPressure, "alert-mem-pressure", opts.AlertThresholds.MemoryPressure, "percentage of time the processes of a job wait for memory above which an alert is raised (0 to disable)")
+	flag.Float64Var(&opts.AlertThresholds.IOPressure, "alert-io-pressure", opts.AlertThresholds.IOPressure, "percentage of time the processes of a job wait for IO above which an alert is raised (0 to disable)")
+	flag.Float64Var(&opts.AlertThresholds.CPUThrottled, "alert-cpu-throttled", opts.AlertThresholds.CPUThrottled, "percentage of CPU periods in which a job is throttled above which an alert is raised (0 to disable)")
+
+	flag.StringVar(&opts.StateDir, "state-dir", "", "directory where the jobs are recorded to keep their status across restarts (empty to not record them)")
+	flag.StringVar(&opts.OutputDir, "output-dir", "", "directory where the output of the jobs is stored when they are not recorded, it is never removed and only bounded by the -output-keep-* and -output-limit flags (empty to keep it in memory)")
+
+	// Output retention
+	var keepFirst, keepLast, outputLimit resourcecontrol.Bytes
+	flag.Var(&keepFirst, "output-keep-first", "amount of the first output of a job kept, dropping the output between it and -output-keep-last, e.g. 1M (0 for none)")
+	flag.Var(&keepLast, "output-keep-last", "amount of the last output of a job kept, dropping the older output, e.g. 10M (0 for none, both 0 to keep the whole output)")
+	flag.Var(&outputLimit, "output-limit", "amount of output of a job kept, the job is stopped once its output goes past it, e.g. 100M (0 for no limit)")
+
+	var doctor bool
+	flag.BoolVar(&doctor, "doctor", false, "report the state of the resource control mechanisms and exit")
 	flag.Parse()
 
+	opts.OutputRetention = multipipe.Retention{First: int64(keepFirst), Last: int64(keepLast), Limit: int64(outputLimit)}
+	if err := opts.OutputRetention.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	for _, name := range strings.Split(inheritEnv, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			opts.EnvPolicy.Inherit = append(opts.EnvPolicy.Inherit, name)
+		}
+	}
+
+	if doctor {
+		diagnosis := resourcecontrol.Diagnose()
+		fmt.Print(diagnosis)
+
+		if !diagnosis.OK() {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := resourcecontrol.Setup(); errors.Is(err, resourcecontrol.ErrMissingControllers) {
+		log.Printf("Warning: %s, run with -doctor for more details.", err)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
 	fmt.Printf("Listening on %s.\n", listen)
 
-	srv, err := server.NewServer(listen, key, cert, ca)
+	srv, err := server.NewServer(listen, key, cert, ca, opts)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -2,6 +2,7 @@ package multipipe
 
 import (
 	"io"
+	"os"
 	"sync"
 )
 
@@ -9,21 +10,54 @@ import (
 
 // MultiPipe is an io.Writer that can create multiple readers from its contents
 type MultiPipe struct {
+	// buf holds the last contents written, all of them unless they are
+	// stored in a file or dropped, and size is the amount written so far
 	buf    []byte
+	size   int64
 	rdErr  error
 	closed bool
 	cond   *sync.Cond
+
+	// retention selects the contents kept, head holds the first ones when
+	// the rest are dropped from memory, punched is the offset up to which the
+	// dropped contents were released from the file, and overflow is closed
+	// once the contents reach the limit
+	retention  Retention
+	head       []byte
+	punched    int64
+	overflow   chan struct{}
+	overflowed bool
+
+	// path is the file the contents are stored in, if any, file is open to
+	// append them unless another writer does it, and only the last tailSize
+	// bytes are kept in buf
+	path     string
+	file     *os.File
+	tailSize int
 }
 
 // Reader is an io.Reader that reads from the beginning of its parent MultiPipe
 // without affecting the other readers
 type Reader struct {
 	parent *MultiPipe
-	offset int
+	offset int64
+
+	// marker is the rest of the truncation marker being read
+	marker []byte
+
+	// file is open while reading the contents that are no longer in memory,
+	// and readingFile is true while it is read without holding the lock
+	file        *os.File
+	readingFile bool
+
+	// closed is set by Close, which wakes up a blocked Read
+	closed bool
 }
 
 // Read reads all the available contents from the MultiPipe parent, then if
-// there is a read error or the stream is closed, an error will be returned
+// there is a read error or the stream is closed, an error will be returned.
+// The contents dropped before the reader got to them are replaced by a marker
+// with their size. Reading from a closed Reader returns io.ErrClosedPipe.
 func (m *Reader) Read(p []byte) (n int, err error) {
 	if len(p) == 0 {
 		return
@@ -32,15 +66,77 @@ func (m *Reader) Read(p []byte) (n int, err error) {
 	m.parent.cond.L.Lock()
 	defer m.parent.cond.L.Unlock()
 
-	// Wait for IO if at the end of the buffer and the input is still open
-	if m.offset >= len(m.parent.buf) && !m.parent.closed {
+	if m.closed {
+		return 0, io.ErrClosedPipe
+	} else if len(m.marker) > 0 {
+		return m.readMarker(p), nil
+	}
+
+	// Wait for IO if at the end of the buffer and the input is still open,
+	// the other readers being closed wake up this one too
+	for m.offset >= m.parent.size && !m.parent.closed && !m.closed {
 		m.parent.cond.Wait()
 	}
 
-	n = copy(p, m.parent.buf[m.offset:])
-	m.offset += n
+	if m.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	start, end := m.parent.retention.retained(m.offset, m.parent.size)
+	if start > m.offset {
+		// Without last contents kept, everything after the first ones is
+		// dropped, so a single marker is read once the pipe is closed
+		for start == end && !m.parent.closed && !m.closed {
+			m.parent.cond.Wait()
+			start, end = m.parent.retention.retained(m.offset, m.parent.size)
+		}
+
+		if m.closed {
+			return 0, io.ErrClosedPipe
+		}
+
+		return m.skip(p, start), nil
+	}
+
+	if int64(len(p)) > end-m.offset {
+		p = p[:end-m.offset]
+	}
+
+	tailStart := m.parent.size - int64(len(m.parent.buf))
+	switch {
+	case m.offset < int64(len(m.parent.head)):
+		n = copy(p, m.parent.head[m.offset:])
+	case m.offset >= tailStart:
+		m.closeFile()
+		n = copy(p, m.parent.buf[m.offset-tailStart:])
+	default:
+		if int64(len(p)) > tailStart-m.offset {
+			p = p[:tailStart-m.offset]
+		}
 
-	if m.offset >= len(m.parent.buf) {
+		// The contents before the tail never change, so they are read
+		// without holding the lock
+		m.readingFile = true
+		m.parent.cond.L.Unlock()
+		n, err = m.readFile(p)
+		m.parent.cond.L.Lock()
+		m.readingFile = false
+
+		// The reader might have been closed, or the contents dropped,
+		// meanwhile though
+		if m.closed {
+			m.closeFile()
+			return 0, io.ErrClosedPipe
+		} else if start, _ := m.parent.retention.retained(m.offset, m.parent.size); start > m.offset {
+			return m.skip(p, start), nil
+		} else if err != nil {
+			m.offset += int64(n)
+			return n, err
+		}
+	}
+	m.offset += int64(n)
+
+	if m.offset >= m.parent.size {
 		if m.parent.rdErr != nil {
 			err = m.parent.rdErr
 		} else if m.parent.closed {
@@ -51,9 +147,139 @@ func (m *Reader) Read(p []byte) (n int, err error) {
 	return
 }
 
+// skip moves the reader to the given offset, past the dropped contents, and
+// reads the marker replacing them
+func (m *Reader) skip(p []byte, offset int64) int {
+	m.marker = truncated(offset - m.offset)
+	m.offset = offset
+
+	return m.readMarker(p)
+}
+
+// readMarker reads the rest of the truncation marker
+func (m *Reader) readMarker(p []byte) int {
+	n := copy(p, m.marker)
+	m.marker = m.marker[n:]
+
+	return n
+}
+
+// readFile reads the stored contents at the offset of the reader, which must
+// be already written
+func (m *Reader) readFile(p []byte) (int, error) {
+	if m.file == nil {
+		f, err := os.Open(m.parent.path)
+		if err != nil {
+			return 0, err
+		}
+		m.file = f
+	}
+
+	n, err := m.file.ReadAt(p, m.offset)
+	if err == io.EOF {
+		// The contents were written, so the file was truncated
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+// closeFile closes the file of the reader once it has caught up with the
+// contents in memory
+func (m *Reader) closeFile() {
+	if m.file != nil {
+		m.file.Close()
+		m.file = nil
+	}
+}
+
+// Close closes the Reader, waking up a Read blocked waiting for new contents,
+// so it can be stopped from another goroutine
+func (m *Reader) Close() error {
+	m.parent.cond.L.Lock()
+	defer m.parent.cond.L.Unlock()
+
+	m.closed = true
+
+	// Otherwise the Read in progress closes the file
+	if !m.readingFile {
+		m.closeFile()
+	}
+	m.parent.cond.Broadcast()
+
+	return nil
+}
+
 // NewMultiPipe creates and initializes a new MultiPipe
 func NewMultiPipe() *MultiPipe {
-	return &MultiPipe{cond: sync.NewCond(&sync.Mutex{})}
+	return NewMultiPipeWithRetention(Retention{})
+}
+
+// NewMultiPipeWithRetention creates a MultiPipe that only keeps the contents
+// selected by the given retention, which must be valid
+func NewMultiPipeWithRetention(r Retention) *MultiPipe {
+	return &MultiPipe{
+		cond:      sync.NewCond(&sync.Mutex{}),
+		retention: r,
+		punched:   r.First,
+		overflow:  make(chan struct{}),
+	}
+}
+
+// NewFileMultiPipe creates a MultiPipe storing its contents in the file at the
+// given path, which is created or truncated, so only the last tailSize bytes
+// are kept in memory. The readers of older contents read them from the file.
+// The contents dropped by the given retention are released from the file.
+func NewFileMultiPipe(path string, tailSize int, r Retention) (*MultiPipe, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	m := NewMultiPipeWithRetention(r)
+	m.path, m.file, m.tailSize = path, f, tailSize
+
+	return m, nil
+}
+
+// OpenFileMultiPipe creates a MultiPipe over the existing file at the given
+// path, which is appended to by another writer, such as a process writing its
+// output to it directly. The MultiPipe holds the current contents of the file,
+// the contents appended later must be written to the MultiPipe too, in order,
+// which only keeps the last tailSize bytes in memory and wakes up its readers.
+// The given retention must be the one the file was written with.
+func OpenFileMultiPipe(path string, tailSize int, r Retention) (*MultiPipe, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	m := NewMultiPipeWithRetention(r)
+	m.path, m.tailSize, m.size = path, tailSize, fi.Size()
+
+	// The other writer might have gone past the limit before being stopped
+	if r.Limit > 0 && m.size > r.Limit {
+		m.size = r.Limit
+		m.setOverflowed()
+	}
+
+	tail := int64(tailSize)
+	if tail > m.size {
+		tail = m.size
+	}
+
+	m.buf = make([]byte, tail)
+	if _, err := f.ReadAt(m.buf, m.size-tail); err != nil {
+		return nil, err
+	}
+
+	return m, nil
 }
 
 // NewReader creates a new Reader that will get its contents from the parent
@@ -62,6 +288,29 @@ func (m *MultiPipe) NewReader() *Reader {
 	return &Reader{parent: m}
 }
 
+// NewTailReader creates a new Reader that will only get the contents written
+// to the parent MultiPipe from now on
+func (m *MultiPipe) NewTailReader() *Reader {
+	m.cond.L.Lock()
+	defer m.cond.L.Unlock()
+
+	return &Reader{parent: m, offset: m.size}
+}
+
+// Overflowed returns a channel that is closed once the contents of the
+// MultiPipe go past the limit of its retention
+func (m *MultiPipe) Overflowed() <-chan struct{} {
+	return m.overflow
+}
+
+// Size returns the amount of bytes written to the MultiPipe so far
+func (m *MultiPipe) Size() int64 {
+	m.cond.L.Lock()
+	defer m.cond.L.Unlock()
+
+	return m.size
+}
+
 // Write writes the given byte slice to the MultiPipe, writing to a closed
 // MultiPipe will result in an error
 func (m *MultiPipe) Write(p []byte) (int, error) {
@@ -74,12 +323,95 @@ func (m *MultiPipe) Write(p []byte) (int, error) {
 
 	if m.closed {
 		return 0, io.ErrClosedPipe
+	} else if m.overflowed {
+		return 0, ErrOutputLimit
+	}
+
+	var limitErr error
+	if limit := m.retention.Limit; limit > 0 && m.size+int64(len(p)) > limit {
+		p, limitErr = p[:limit-m.size], ErrOutputLimit
+		m.setOverflowed()
+	}
+
+	if m.file != nil {
+		if n, err := m.file.Write(p); err != nil {
+			m.append(p[:n])
+			return n, err
+		}
+	}
+
+	m.append(p)
+
+	return len(p), limitErr
+}
+
+// setOverflowed records that the contents reached the limit
+func (m *MultiPipe) setOverflowed() {
+	m.overflowed = true
+	close(m.overflow)
+}
+
+// append adds the given contents, already stored if there is a file, to the
+// ones in memory and wakes up the readers
+func (m *MultiPipe) append(p []byte) {
+	// Without a file, the first contents kept are held apart from the last
+	// ones
+	if m.path == "" && m.size < m.retention.First {
+		n := len(p)
+		if int64(n) > m.retention.First-m.size {
+			n = int(m.retention.First - m.size)
+		}
+
+		m.head = append(m.head, p[:n]...)
+		m.size += int64(n)
+		p = p[n:]
 	}
 
 	m.buf = append(m.buf, p...)
+	m.size += int64(len(p))
+
+	// The buffer is trimmed once it doubles the tail, so the contents are
+	// not moved on every write
+	if tailSize, ok := m.tailLimit(); ok && len(m.buf) > 2*tailSize {
+		m.buf = m.buf[:copy(m.buf, m.buf[len(m.buf)-tailSize:])]
+	}
+
+	m.release()
 	m.cond.Broadcast()
+}
 
-	return len(p), nil
+// tailLimit returns the amount of the last contents that must be kept in
+// memory, and false if all of them are
+func (m *MultiPipe) tailLimit() (int, bool) {
+	switch {
+	case m.path != "":
+		return m.tailSize, true
+	case m.retention.drops():
+		return int(m.retention.Last), true
+	}
+
+	return 0, false
+}
+
+// release releases the contents dropped from the file, once there are enough
+// of them, so it does not grow without bounds
+func (m *MultiPipe) release() {
+	if m.path == "" || !m.retention.drops() || m.punched < 0 {
+		return
+	}
+
+	dropEnd := m.size - m.retention.Last
+	if dropEnd-m.punched < punchSize {
+		return
+	}
+
+	if err := punchHole(m.path, m.punched, dropEnd-m.punched); err != nil {
+		// The file system does not support it, the contents are still
+		// dropped for the readers
+		m.punched = -1
+		return
+	}
+	m.punched = dropEnd
 }
 
 // Close closes the MultiPipe without errors
@@ -96,10 +428,17 @@ func (m *MultiPipe) CloseWithError(err error) error {
 
 	if m.closed {
 		return io.ErrClosedPipe
+	} else if m.overflowed {
+		// The contents end because of the limit, whatever stopped the writer
+		m.rdErr = ErrOutputLimit
 	} else if err != nil {
 		m.rdErr = err
 	}
 
+	if m.file != nil {
+		m.file.Close()
+	}
+
 	m.closed = true
 	m.cond.Broadcast()
 
@@ -2,8 +2,12 @@ package multipipe
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"os"
+	"path"
 	"testing"
+	"time"
 )
 
 func TestReader(t *testing.T) {
@@ -25,3 +29,145 @@ func TestReader(t *testing.T) {
 		t.Errorf("expected '%s', got '%s'", string(testPhrase), string(out))
 	}
 }
+
+func TestTailReader(t *testing.T) {
+	mp := NewMultiPipe()
+	mp.Write([]byte("skipped "))
+
+	rd := mp.NewTailReader()
+
+	testPhrase := []byte("hello tail")
+	mp.Write(testPhrase)
+	mp.Close()
+
+	out, err := io.ReadAll(rd)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(out, testPhrase) {
+		t.Errorf("expected '%s', got '%s'", string(testPhrase), string(out))
+	}
+}
+
+func TestFileMultiPipe(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "out")
+
+	mp, err := NewFileMultiPipe(filePath, 4, Retention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testPhrase := []byte("hello file multipipe")
+	mp.Write(testPhrase[:10])
+
+	// The reader gets the start from the file and then waits for the rest
+	rd := mp.NewReader()
+	go func() {
+		mp.Write(testPhrase[10:])
+		mp.Close()
+	}()
+
+	out, err := io.ReadAll(rd)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(out, testPhrase) {
+		t.Errorf("expected '%s', got '%s'", string(testPhrase), string(out))
+	}
+
+	if len(mp.buf) > 8 {
+		t.Errorf("expected at most 8 bytes in memory, got %d", len(mp.buf))
+	}
+
+	if stored, err := os.ReadFile(filePath); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(stored, testPhrase) {
+		t.Errorf("expected '%s', got '%s'", string(testPhrase), string(stored))
+	}
+}
+
+func TestOpenFileMultiPipe(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "out")
+
+	testPhrase := []byte("hello open multipipe")
+	if err := os.WriteFile(filePath, testPhrase[:12], 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	mp, err := OpenFileMultiPipe(filePath, 4, Retention{})
+	if err != nil {
+		t.Fatal(err)
+	} else if mp.Size() != 12 {
+		t.Errorf("expected 12 bytes, got %d", mp.Size())
+	}
+
+	rd, tailRd := mp.NewReader(), mp.NewTailReader()
+
+	// The other writer appends to the file before the pipe is told about it
+	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(testPhrase[12:])
+	f.Close()
+
+	mp.Write(testPhrase[12:])
+	mp.Close()
+
+	out, err := io.ReadAll(rd)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(out, testPhrase) {
+		t.Errorf("expected '%s', got '%s'", string(testPhrase), string(out))
+	}
+
+	out, err = io.ReadAll(tailRd)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(out, testPhrase[12:]) {
+		t.Errorf("expected '%s', got '%s'", string(testPhrase[12:]), string(out))
+	}
+}
+
+func TestCloseReader(t *testing.T) {
+	mp := NewMultiPipe()
+	mp.Write([]byte("hello"))
+
+	rd := mp.NewReader()
+	buf := make([]byte, 8)
+	if n, err := rd.Read(buf); err != nil || string(buf[:n]) != "hello" {
+		t.Errorf("expected 'hello', got '%s' (%v)", buf[:n], err)
+	}
+
+	// The reader is blocked until it is closed
+	readErr := make(chan error)
+	go func() {
+		_, err := rd.Read(buf)
+		readErr <- err
+	}()
+	rd.Close()
+
+	if err := <-readErr; err != io.ErrClosedPipe {
+		t.Errorf("expected '%s', got '%v'", io.ErrClosedPipe, err)
+	}
+}
+
+func TestCloseOtherReader(t *testing.T) {
+	mp := NewMultiPipe()
+	closed, blocked := mp.NewReader(), mp.NewReader()
+
+	outCh := make(chan string)
+	go func() {
+		buf := make([]byte, 8)
+		n, err := blocked.Read(buf)
+		outCh <- fmt.Sprintf("%s (%v)", buf[:n], err)
+	}()
+
+	// Closing a reader wakes up the others, which keep waiting for contents
+	time.Sleep(10 * time.Millisecond)
+	closed.Close()
+	time.Sleep(10 * time.Millisecond)
+	mp.Write([]byte("hello"))
+
+	if out, expected := <-outCh, "hello (<nil>)"; out != expected {
+		t.Errorf("expected '%s', got '%s'", expected, out)
+	}
+}
@@ -0,0 +1,101 @@
+package multipipe
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// truncatedMarker is returned by a Reader in place of the contents that
+	// were dropped before it could read them
+	truncatedMarker = "[output truncated, skipped %d bytes]\n"
+
+	// punchSize is the minimum amount of dropped contents released from the
+	// file of a MultiPipe at once
+	punchSize = 1 << 20
+)
+
+var (
+	ErrNegativeRetention = errors.New("the retained amounts cannot be negative")
+	ErrLimitWithDrop     = errors.New("an output limit cannot be combined with keeping the first or last contents")
+	ErrOutputLimit       = errors.New("output limit exceeded")
+)
+
+// Retention selects the contents of a MultiPipe that are kept, its zero value
+// keeps all of them
+type Retention struct {
+	// First and Last, when either of them is set, are the amount of bytes
+	// kept from the start and from the end of the contents, the rest are
+	// dropped as new ones are written
+	First int64 `json:"first,omitempty"`
+	Last  int64 `json:"last,omitempty"`
+
+	// Limit, when set, is the amount of bytes kept, the writes going past it
+	// fail with ErrOutputLimit and close Overflowed
+	Limit int64 `json:"limit,omitempty"`
+}
+
+// Validate returns an error if the retention is not valid
+func (r Retention) Validate() error {
+	switch {
+	case r.First < 0 || r.Last < 0 || r.Limit < 0:
+		return ErrNegativeRetention
+	case r.Limit > 0 && r.drops():
+		return ErrLimitWithDrop
+	}
+
+	return nil
+}
+
+// Max returns the maximum amount of bytes kept, zero if it is not bounded
+func (r Retention) Max() int64 {
+	if r.drops() {
+		return r.First + r.Last
+	}
+
+	return r.Limit
+}
+
+// drops returns true if some of the contents are dropped as new ones are
+// written
+func (r Retention) drops() bool {
+	return r.First > 0 || r.Last > 0
+}
+
+// retained returns the start of the first contents kept at or after the given
+// offset of a MultiPipe with the given size, which is the offset itself unless
+// it was dropped, along with the end of the contiguous contents kept from there
+func (r Retention) retained(offset, size int64) (start, end int64) {
+	lastStart := size - r.Last
+
+	switch {
+	case !r.drops() || lastStart <= r.First:
+		return offset, size
+	case offset < r.First:
+		return offset, r.First
+	case offset < lastStart:
+		return lastStart, size
+	}
+
+	return offset, size
+}
+
+// punchHole releases the disk space of the given range of the file at the
+// given path, which reads as zeros from then on
+func punchHole(path string, offset, length int64) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_PUNCH_HOLE|unix.FALLOC_FL_KEEP_SIZE, offset, length)
+}
+
+// truncated returns the marker of the given amount of dropped contents
+func truncated(skipped int64) []byte {
+	return []byte(fmt.Sprintf(truncatedMarker, skipped))
+}
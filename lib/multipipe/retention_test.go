@@ -0,0 +1,162 @@
+package multipipe
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestRetainLast(t *testing.T) {
+	mp := NewMultiPipeWithRetention(Retention{Last: 8})
+
+	rd := mp.NewReader()
+	mp.Write([]byte("hello"))
+
+	// The reader started before the contents were dropped
+	buf := make([]byte, 2)
+	if n, err := rd.Read(buf); err != nil || string(buf[:n]) != "he" {
+		t.Errorf("expected 'he', got '%s' (%v)", buf[:n], err)
+	}
+
+	mp.Write([]byte(" retention"))
+	mp.Close()
+
+	if out, err := io.ReadAll(rd); err != nil {
+		t.Fatal(err)
+	} else if expected := "[output truncated, skipped 5 bytes]\netention"; string(out) != expected {
+		t.Errorf("expected '%s', got '%s'", expected, out)
+	}
+
+	out, err := io.ReadAll(mp.NewReader())
+	if err != nil {
+		t.Fatal(err)
+	} else if expected := "[output truncated, skipped 7 bytes]\netention"; string(out) != expected {
+		t.Errorf("expected '%s', got '%s'", expected, out)
+	}
+}
+
+func TestRetainFirstLast(t *testing.T) {
+	mp := NewMultiPipeWithRetention(Retention{First: 5, Last: 4})
+
+	mp.Write([]byte("hello"))
+	mp.Write([]byte(" first"))
+	mp.Write([]byte(" and last"))
+	mp.Close()
+
+	out, err := io.ReadAll(mp.NewReader())
+	if err != nil {
+		t.Fatal(err)
+	} else if expected := "hello[output truncated, skipped 11 bytes]\nlast"; string(out) != expected {
+		t.Errorf("expected '%s', got '%s'", expected, out)
+	}
+
+	if len(mp.head)+len(mp.buf) > 5+2*4 {
+		t.Errorf("expected at most 13 bytes in memory, got %d", len(mp.head)+len(mp.buf))
+	}
+}
+
+func TestRetainFirst(t *testing.T) {
+	mp := NewMultiPipeWithRetention(Retention{First: 5})
+	mp.Write([]byte("hello"))
+
+	rd := mp.NewReader()
+	buf := make([]byte, 8)
+	if n, err := rd.Read(buf); err != nil || string(buf[:n]) != "hello" {
+		t.Errorf("expected 'hello', got '%s' (%v)", buf[:n], err)
+	}
+
+	// The reader waits at the end while the contents are dropped
+	outCh := make(chan []byte)
+	go func() {
+		out, _ := io.ReadAll(rd)
+		outCh <- out
+	}()
+
+	for _, p := range []string{" first", " only"} {
+		time.Sleep(10 * time.Millisecond)
+		mp.Write([]byte(p))
+	}
+	mp.Close()
+
+	if out, expected := <-outCh, "[output truncated, skipped 11 bytes]\n"; string(out) != expected {
+		t.Errorf("expected '%s', got '%s'", expected, out)
+	}
+}
+
+func TestRetainLastFile(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "out")
+
+	mp, err := NewFileMultiPipe(filePath, 16, Retention{First: 8, Last: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunk := bytes.Repeat([]byte("0123456789abcdef"), 1024)
+	for i := 0; i < 2*punchSize/len(chunk); i++ {
+		mp.Write(chunk)
+	}
+	mp.Close()
+
+	out, err := io.ReadAll(mp.NewReader())
+	if err != nil {
+		t.Fatal(err)
+	} else if expected := "01234567[output truncated, skipped 2097136 bytes]\n89abcdef"; string(out) != expected {
+		t.Errorf("expected '%s', got '%s'", expected, out)
+	}
+
+	// The dropped contents are released unless the file system does not
+	// support it
+	if mp.punched > 0 {
+		stored, err := os.ReadFile(filePath)
+		if err != nil {
+			t.Fatal(err)
+		} else if stored[8] != 0 || stored[mp.punched-1] != 0 {
+			t.Error("expected the dropped contents to be released")
+		}
+	}
+}
+
+func TestOutputLimit(t *testing.T) {
+	mp := NewMultiPipeWithRetention(Retention{Limit: 8})
+
+	if n, err := mp.Write([]byte("hello")); n != 5 || err != nil {
+		t.Errorf("expected 5 bytes, got %d (%v)", n, err)
+	}
+
+	if n, err := mp.Write([]byte(" limit")); n != 3 || err != ErrOutputLimit {
+		t.Errorf("expected 3 bytes and '%s', got %d (%v)", ErrOutputLimit, n, err)
+	}
+
+	select {
+	case <-mp.Overflowed():
+	default:
+		t.Error("expected the pipe to be overflowed")
+	}
+
+	if _, err := mp.Write([]byte("!")); err != ErrOutputLimit {
+		t.Errorf("expected '%s', got '%v'", ErrOutputLimit, err)
+	}
+	mp.Close()
+
+	out, err := io.ReadAll(mp.NewReader())
+	if err != ErrOutputLimit || string(out) != "hello li" {
+		t.Errorf("expected 'hello li' and '%s', got '%s' (%v)", ErrOutputLimit, out, err)
+	}
+}
+
+func TestValidateRetention(t *testing.T) {
+	for r, expected := range map[Retention]error{
+		{}:                  nil,
+		{First: 1, Last: 2}: nil,
+		{Limit: 3}:          nil,
+		{Last: -1}:          ErrNegativeRetention,
+		{Last: 1, Limit: 3}: ErrLimitWithDrop,
+	} {
+		if err := r.Validate(); err != expected {
+			t.Errorf("expected '%v' for %+v, got '%v'", expected, r, err)
+		}
+	}
+}
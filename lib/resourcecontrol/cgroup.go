@@ -0,0 +1,318 @@
+package resourcecontrol
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// eventsTimeout is how long to wait for a cgroup state change
+	eventsTimeout = 5 * time.Second
+
+	// eventsPollInterval is how often cgroup.events is read while waiting
+	eventsPollInterval = 10 * time.Millisecond
+
+	// monitorCgroupName is the leaf cgroup shared by the monitors of the
+	// commands run with a state directory, so they do not count against the
+	// limits of the commands. The names of the cgroups of the commands are
+	// UUIDs, which cannot clash with it.
+	monitorCgroupName = "monitors"
+)
+
+var (
+	errInvalidCgroupName = errors.New("invalid cgroup name")
+	errEventsTimeout     = errors.New("timed out waiting for the cgroup state to change")
+)
+
+// Cgroup represents the leaf cgroup of a single job, created under the
+// controlSubtree cgroup
+type Cgroup struct {
+	path string
+}
+
+// enableControllers enables the given controllers for the children of the
+// cgroup at the given path
+func enableControllers(cgroupPath string, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	var ctrls []string
+	for _, c := range names {
+		ctrls = append(ctrls, "+"+c)
+	}
+
+	return os.WriteFile(path.Join(cgroupPath, "cgroup.subtree_control"), []byte(strings.Join(ctrls, " ")), 0700)
+}
+
+// newCgroup creates a new leaf cgroup with the given name and controllers, the
+// controlSubtree parent is created too if needed
+func newCgroup(name string, ctrls []string) (*Cgroup, error) {
+	if name == "" || strings.ContainsRune(name, '/') {
+		return nil, errInvalidCgroupName
+	}
+
+	rootPath, err := getCgroupRootPath()
+	if err != nil {
+		return nil, err
+	}
+
+	parentPath := path.Join(rootPath, controlSubtree)
+	if err := os.Mkdir(parentPath, 0755); err != nil && !os.IsExist(err) {
+		return nil, err
+	}
+
+	if err := enableControllers(parentPath, ctrls); err != nil {
+		return nil, err
+	}
+
+	cgroupPath := path.Join(parentPath, name)
+	if err := os.Mkdir(cgroupPath, 0755); err != nil {
+		return nil, err
+	}
+
+	return &Cgroup{path: cgroupPath}, nil
+}
+
+// existingCgroup returns the leaf cgroup with the given name, created before by
+// newCgroup, it might have been removed since then
+func existingCgroup(name string) (*Cgroup, error) {
+	if name == "" || strings.ContainsRune(name, '/') {
+		return nil, errInvalidCgroupName
+	}
+
+	rootPath, err := getCgroupRootPath()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cgroup{path: path.Join(rootPath, controlSubtree, name)}, nil
+}
+
+// monitorCgroup returns the cgroup of the monitors, creating it if needed
+func monitorCgroup() (*Cgroup, error) {
+	c, err := newCgroup(monitorCgroupName, nil)
+	if os.IsExist(err) {
+		return existingCgroup(monitorCgroupName)
+	}
+
+	return c, err
+}
+
+// Path returns the absolute path of the cgroup
+func (c *Cgroup) Path() string {
+	return c.path
+}
+
+// Remove deletes the cgroup, it will fail if there are still processes in it
+func (c *Cgroup) Remove() error {
+	return os.Remove(c.path)
+}
+
+func (c *Cgroup) write(file, value string) error {
+	return os.WriteFile(path.Join(c.path, file), []byte(value), 0700)
+}
+
+// isRemoved returns true if the error was caused by the cgroup being removed,
+// files of a cgroup being removed return ENODEV instead of ENOENT
+func isRemoved(err error) bool {
+	return os.IsNotExist(err) || errors.Is(err, unix.ENODEV)
+}
+
+// readKeyed parses a flat keyed file of the cgroup, such as cgroup.events,
+// made of "key value" lines
+func (c *Cgroup) readKeyed(file string) (map[string]string, error) {
+	out, err := os.ReadFile(path.Join(c.path, file))
+	if err != nil {
+		return nil, err
+	}
+
+	kvs := make(map[string]string)
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		if fs := strings.Fields(sc.Text()); len(fs) == 2 {
+			kvs[fs[0]] = fs[1]
+		}
+	}
+
+	return kvs, sc.Err()
+}
+
+// event returns the value of the given key in cgroup.events
+func (c *Cgroup) event(key string) (string, error) {
+	kvs, err := c.readKeyed("cgroup.events")
+	return kvs[key], err
+}
+
+// MemoryEvents returns the counters of memory.events, such as "oom_kill". An
+// empty map is returned if the memory controller is not enabled.
+func (c *Cgroup) MemoryEvents() (map[string]int64, error) {
+	events, err := c.readCounters("memory.events")
+	if os.IsNotExist(err) {
+		return map[string]int64{}, nil
+	}
+
+	return events, err
+}
+
+// waitEvent blocks until the given key of cgroup.events has the given value or
+// the timeout expires
+func (c *Cgroup) waitEvent(key, value string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		v, err := c.event(key)
+		if err != nil {
+			return err
+		} else if v == value {
+			return nil
+		} else if time.Now().After(deadline) {
+			return errEventsTimeout
+		}
+
+		time.Sleep(eventsPollInterval)
+	}
+}
+
+// Frozen returns true if the processes in the cgroup are frozen
+func (c *Cgroup) Frozen() (bool, error) {
+	v, err := c.event("frozen")
+	return v == "1", err
+}
+
+// Populated returns true if there are processes in the cgroup
+func (c *Cgroup) Populated() (bool, error) {
+	v, err := c.event("populated")
+	return v == "1", err
+}
+
+// Procs returns the PIDs of the processes in the cgroup
+func (c *Cgroup) Procs() ([]int, error) {
+	out, err := os.ReadFile(path.Join(c.path, "cgroup.procs"))
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+	for _, f := range strings.Fields(string(out)) {
+		pid, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, err
+		}
+		pids = append(pids, pid)
+	}
+
+	return pids, nil
+}
+
+// Signal sends the given signal to every process in the cgroup
+func (c *Cgroup) Signal(sig unix.Signal) error {
+	pids, err := c.Procs()
+	if isRemoved(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, pid := range pids {
+		// The process may have exited in the meantime
+		if err := unix.Kill(pid, sig); err != nil && err != unix.ESRCH {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WaitEmpty waits up to the given timeout for all the processes in the cgroup
+// to exit, returning false if some of them are still running
+func (c *Cgroup) WaitEmpty(timeout time.Duration) (bool, error) {
+	err := c.waitEvent("populated", "0", timeout)
+
+	switch {
+	case err == errEventsTimeout:
+		return false, nil
+	case isRemoved(err):
+		return true, nil
+	}
+
+	return err == nil, err
+}
+
+// Kill kills every process in the cgroup and waits until it is empty. On
+// kernels without cgroup.kill the cgroup is frozen, so no new processes can be
+// forked, and its processes are killed one by one.
+func (c *Cgroup) Kill() error {
+	err := c.kill()
+	if isRemoved(err) {
+		// The cgroup has already been removed, so it was empty
+		return nil
+	}
+
+	return err
+}
+
+func (c *Cgroup) kill() error {
+	if _, err := os.Stat(path.Join(c.path, "cgroup.kill")); err == nil {
+		if err := c.write("cgroup.kill", "1"); err != nil {
+			return err
+		}
+	} else if err := c.freezeAndKill(); err != nil {
+		return err
+	}
+
+	return c.waitEvent("populated", "0", eventsTimeout)
+}
+
+// Freeze freezes every process in the cgroup and waits until all of them are
+// frozen. On failure the cgroup is thawed, so it is not left half frozen.
+func (c *Cgroup) Freeze() error {
+	if err := c.write("cgroup.freeze", "1"); err != nil {
+		return err
+	}
+
+	if err := c.waitEvent("frozen", "1", eventsTimeout); err != nil {
+		_ = c.write("cgroup.freeze", "0")
+		return err
+	}
+
+	return nil
+}
+
+// Thaw resumes the processes in a frozen cgroup and waits until all of them
+// are running again. On failure the cgroup is frozen again, as it was.
+func (c *Cgroup) Thaw() error {
+	if err := c.write("cgroup.freeze", "0"); err != nil {
+		return err
+	}
+
+	// The processes may exit and the cgroup be removed right after thawing
+	if err := c.waitEvent("frozen", "0", eventsTimeout); err != nil && !isRemoved(err) {
+		_ = c.write("cgroup.freeze", "1")
+		return err
+	}
+
+	return nil
+}
+
+func (c *Cgroup) freezeAndKill() error {
+	if err := c.Freeze(); err != nil {
+		return err
+	}
+
+	if err := c.Signal(unix.SIGKILL); err != nil {
+		return err
+	}
+
+	// Killed processes exit even while frozen, thawing lets the cgroup be
+	// reused
+	return c.write("cgroup.freeze", "0")
+}
@@ -1,39 +1,367 @@
 package resourcecontrol
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"os"
 	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
 )
 
 type Cmd struct {
 	*exec.Cmd
+
+	// CgroupName is the name of the leaf cgroup the command will run in, a
+	// random one is generated by Start if left empty
+	CgroupName string
+
+	// EnvPolicy selects the variables of the current process inherited by the
+	// command, none but a safe PATH by default
+	EnvPolicy EnvPolicy
+
+	// TTY runs the command in a new session with a pseudo-terminal as its
+	// controlling terminal and standard streams, its output is copied to
+	// Stdout and its input is written through Terminal
+	TTY bool
+
+	// StateDir runs the command under a monitor process, which records the
+	// PID and the exit status of the command in the given directory, so the
+	// command can outlive the current process and be recovered by Recover.
+	// The monitor runs in a cgroup shared by the monitors, outside of the
+	// cgroup of the command. The standard streams of the command should be
+	// files for it to keep running then. It cannot be combined with TTY.
+	StateDir string
+
+	limits    ResourceLimits
+	env       map[string]string
+	cgroup    *Cgroup
+	oomKilled bool
+	terminal  *Terminal
+	ptyOut    io.Writer
+	ptyDone   chan error
+
+	// joined is set when the command runs in the cgroup of another command,
+	// which it does not own, and exited is closed once it has been waited for
+	joined bool
+	exited chan struct{}
+
+	// pid is the PID of the main process of a command run under a monitor,
+	// recovered is set for the commands started by another process, along
+	// with monitorStart, the start time of their monitor, and waitStatus is
+	// the status recorded by the monitor
+	pid          int
+	recovered    bool
+	monitorStart uint64
+	waitStatus   *syscall.WaitStatus
+}
+
+func randomName() (string, error) {
+	uuid, err := os.ReadFile("/proc/sys/kernel/random/uuid")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(uuid)), nil
 }
 
-// Start wraps exec.Cmd.Start and adds a signalling pipe to catch errors earlier
-func (c *Cmd) Start() error {
+// SetEnv sets the given environment variables for the command, overriding the
+// inherited ones with the same name. They are validated when the command is
+// started.
+func (c *Cmd) SetEnv(env map[string]string) {
+	c.env = env
+}
+
+// Start validates the resource limits and the environment, creates the leaf
+// cgroup of the command, unless it joins an existing one, and wraps
+// exec.Cmd.Start, adding a signalling pipe to catch errors earlier
+func (c *Cmd) Start() (err error) {
+	if err = c.limits.Validate(); err != nil {
+		return err
+	}
+
+	if c.StateDir != "" && c.TTY {
+		return ErrStateDirWithTTY
+	}
+
+	if err = ValidateEnv(c.env); err != nil {
+		return err
+	}
+	c.Env = append(append(c.EnvPolicy.environ(), c.Env...), renderEnv(c.env)...)
+
+	if !c.joined {
+		for _, ev := range genLimitsEnvVars(c.limits) {
+			c.Env = append(c.Env, ev.String())
+		}
+
+		if c.CgroupName == "" {
+			if c.CgroupName, err = randomName(); err != nil {
+				return err
+			}
+		}
+
+		if c.cgroup, err = newCgroup(c.CgroupName, c.limits.controllers()); err != nil {
+			return err
+		}
+	}
+	c.Env = append(c.Env, cgroupEnvVar+"="+c.cgroup.Path())
+
+	if c.StateDir != "" {
+		monitors, err := monitorCgroup()
+		if err != nil {
+			c.removeCgroup()
+			return err
+		}
+		c.Env = append(c.Env, stateDirEnvVar+"="+c.StateDir, monitorEnvVar+"="+monitors.Path())
+
+		// The monitor must not get the signals of the session of the
+		// current process, such as SIGHUP or SIGINT
+		if c.SysProcAttr == nil {
+			c.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		c.SysProcAttr.Setsid = true
+	}
+
 	r, w, err := os.Pipe()
 	if err != nil {
+		c.removeCgroup()
 		return err
 	}
+	defer r.Close()
 
 	c.ExtraFiles = []*os.File{w}
 
-	if err = c.Cmd.Start(); err != nil {
+	var slave *os.File
+	if c.TTY {
+		if slave, err = c.setupPTY(); err != nil {
+			c.removeCgroup()
+			return err
+		}
+	}
+
+	err = c.Cmd.Start()
+	if slave != nil {
+		// The command holds its own copy of the slave side
+		slave.Close()
+	}
+
+	if err != nil {
+		if c.terminal != nil {
+			c.terminal.master.Close()
+		}
+		c.removeCgroup()
 		return err
 	}
+	c.exited = make(chan struct{})
+
+	if c.terminal != nil {
+		c.ptyDone = make(chan error, 1)
+		go func() {
+			c.ptyDone <- copyPTY(c.ptyOut, c.terminal.master)
+		}()
+	}
 
 	if err = w.Close(); err != nil {
-		return err
+		return c.abort(err)
 	}
 
 	out, err := io.ReadAll(r)
 	if err != nil {
-		return err
+		return c.abort(err)
 	} else if len(out) > 0 {
+		_ = c.Wait()
 		return errors.New(string(out))
 	}
 
+	if c.StateDir != "" {
+		if c.pid, err = readIntFile(c.StateDir, pidFile); err != nil {
+			return c.abort(err)
+		}
+	}
+
 	return nil
 }
+
+// abort kills a command that started but failed to report it and waits for it,
+// which also removes its leaf cgroup, then returns the given error
+func (c *Cmd) abort(err error) error {
+	_ = c.Process.Kill()
+	_ = c.Wait()
+
+	return err
+}
+
+// removeCgroup removes the leaf cgroup of a command that failed to start,
+// unless it belongs to another command
+func (c *Cmd) removeCgroup() {
+	if !c.joined {
+		_ = c.cgroup.Remove()
+	}
+}
+
+// Wait wraps exec.Cmd.Wait, then kills the processes left behind by the
+// command and removes the leaf cgroup. The cgroup of another command is left
+// untouched along with its processes. For a command run under a monitor, it
+// waits for the monitor and returns an ExitError if the command did not exit
+// successfully.
+func (c *Cmd) Wait() error {
+	var err error
+	if c.StateDir != "" {
+		err = c.waitMonitor()
+	} else {
+		err = c.Cmd.Wait()
+	}
+	close(c.exited)
+
+	var rmErr error
+	if !c.joined {
+		// memory.events is gone along with the cgroup, read it beforehand
+		if events, evErr := c.cgroup.MemoryEvents(); evErr == nil {
+			c.oomKilled = events["oom_kill"] > 0
+		}
+
+		var populated bool
+		populated, rmErr = c.cgroup.Populated()
+		if rmErr == nil && populated {
+			rmErr = c.cgroup.Kill()
+		}
+
+		if rmErr == nil {
+			rmErr = c.cgroup.Remove()
+		}
+
+		// The cgroup of a recovered command might have been removed while
+		// nobody was waiting for it
+		if isRemoved(rmErr) {
+			rmErr = nil
+		}
+
+		if err == nil {
+			err = rmErr
+		}
+	}
+
+	if c.terminal != nil {
+		// Some processes might still hold the slave side if they could not
+		// be killed, closing the master stops the copy anyway
+		if rmErr != nil {
+			c.terminal.master.Close()
+		}
+
+		if ptyErr := <-c.ptyDone; err == nil {
+			err = ptyErr
+		}
+		c.terminal.master.Close()
+	}
+
+	return err
+}
+
+// setupPTY allocates the pseudo-terminal of the command and makes its slave
+// side the standard streams and controlling terminal of the command, which
+// must be closed once the command has started
+func (c *Cmd) setupPTY() (*os.File, error) {
+	master, slave, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+
+	c.terminal = &Terminal{master: master}
+	c.Stdin, c.Stderr = slave, slave
+	c.Stdout, c.ptyOut = slave, c.Stdout
+	if c.ptyOut == nil {
+		c.ptyOut = io.Discard
+	}
+
+	if c.SysProcAttr == nil {
+		c.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	c.SysProcAttr.Setsid = true
+	c.SysProcAttr.Setctty = true
+	c.SysProcAttr.Ctty = 0
+
+	return slave, nil
+}
+
+// Signal sends the given signal to every process of the command, or only to
+// its main process if it joined the cgroup of another command
+func (c *Cmd) Signal(sig unix.Signal) error {
+	if c.joined {
+		return c.SignalProcess(sig)
+	}
+
+	return c.cgroup.Signal(sig)
+}
+
+// WaitExited waits up to the given timeout for the processes of the command to
+// exit, returning false if some of them are still running. A command that
+// joined the cgroup of another one only waits for Wait to return.
+func (c *Cmd) WaitExited(timeout time.Duration) (bool, error) {
+	if !c.joined {
+		return c.cgroup.WaitEmpty(timeout)
+	}
+
+	select {
+	case <-c.exited:
+		return true, nil
+	case <-time.After(timeout):
+		return false, nil
+	}
+}
+
+// Kill kills the command along with all of its descendants and waits until no
+// processes are left in its cgroup. A command that joined the cgroup of
+// another one only gets its main process killed.
+func (c *Cmd) Kill() error {
+	if c.joined {
+		if err := c.SignalProcess(unix.SIGKILL); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			return err
+		}
+
+		return nil
+	}
+
+	return c.cgroup.Kill()
+}
+
+// OOMKilled reports whether the OOM killer killed any process of the command,
+// it is only available after Wait has returned and never set for a command
+// that joined the cgroup of another one
+func (c *Cmd) OOMKilled() bool {
+	return c.oomKilled
+}
+
+// Terminal returns the pseudo-terminal of the command, it is only available
+// after Start has been called with TTY set
+func (c *Cmd) Terminal() *Terminal {
+	return c.terminal
+}
+
+// Run starts the command and waits for it to complete
+func (c *Cmd) Run() error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+
+	return c.Wait()
+}
+
+// Output runs the command and returns its standard output
+func (c *Cmd) Output() ([]byte, error) {
+	var stdout bytes.Buffer
+	c.Stdout = &stdout
+
+	err := c.Run()
+
+	return stdout.Bytes(), err
+}
+
+// Cgroup returns the leaf cgroup of the command, it is only available after
+// Start has been called
+func (c *Cmd) Cgroup() *Cgroup {
+	return c.cgroup
+}
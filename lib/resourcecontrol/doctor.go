@@ -0,0 +1,122 @@
+package resourcecontrol
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Check is the result of a single diagnostic check
+type Check struct {
+	Name   string
+	OK     bool
+	Detail string
+}
+
+func (c Check) String() string {
+	result := " OK "
+	if !c.OK {
+		result = "FAIL"
+	}
+
+	return fmt.Sprintf("[%s] %s: %s", result, c.Name, c.Detail)
+}
+
+// Diagnosis holds the results of the checks performed by Diagnose
+type Diagnosis []Check
+
+// OK returns true if all the checks succeeded
+func (d Diagnosis) OK() bool {
+	for _, c := range d {
+		if !c.OK {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (d Diagnosis) String() string {
+	var sb strings.Builder
+
+	for _, c := range d {
+		sb.WriteString(c.String() + "\n")
+	}
+
+	return sb.String()
+}
+
+// Diagnose inspects the host, without modifying it, and reports which mounts,
+// permissions and controllers are available and which limits will not be
+// applied to the jobs
+func Diagnose() Diagnosis {
+	var d Diagnosis
+
+	rootPath, err := findMountPoint("cgroup2")
+	switch {
+	case err != nil:
+		return append(d, Check{"cgroup2 mount", false, err.Error()})
+	case rootPath == "":
+		return append(d, Check{"cgroup2 mount", false, "not mounted, the server will try to mount it on startup"})
+	}
+	d = append(d, Check{"cgroup2 mount", true, "mounted at " + rootPath})
+
+	writable := unix.Access(rootPath, unix.W_OK) == nil
+	permDetail := "the cgroup2 hierarchy is writable"
+	if !writable {
+		permDetail = "no write access to " + rootPath + ", run the server as a privileged user"
+	}
+	d = append(d, Check{"permissions", writable, permDetail})
+
+	subtreePath := path.Join(rootPath, controlSubtree)
+	available, err := readControllers(rootPath, "cgroup.controllers")
+	if err != nil {
+		return append(d, Check{"controllers", false, err.Error()})
+	}
+
+	// Missing files mean that the subtree has not been set up yet
+	delegated, _ := readControllers(rootPath, "cgroup.subtree_control")
+	leafDelegated, _ := readControllers(subtreePath, "cgroup.subtree_control")
+
+	for _, c := range supportedControllers {
+		check := Check{"controller " + c, true, "enabled for the jobs"}
+
+		switch {
+		case !available[c]:
+			check.OK, check.Detail = false, "not available in the cgroup2 hierarchy, it may be bound to a cgroup v1 hierarchy"
+		case !delegated[c] || !leafDelegated[c]:
+			check.OK, check.Detail = false, "available but not enabled, the server will try to enable it on startup"
+		}
+
+		d = append(d, check)
+	}
+
+	files := []string{"io.max"}
+	for _, lf := range limitFiles {
+		files = append(files, lf.file)
+	}
+
+	for _, f := range files {
+		ctrl := strings.SplitN(f, ".", 2)[0]
+		check := Check{"limit " + f, true, "will be applied"}
+
+		if !leafDelegated[ctrl] {
+			check.OK, check.Detail = false, "will not be applied, the "+ctrl+" controller is not enabled"
+		} else if _, err := os.Stat(path.Join(subtreePath, f)); err != nil {
+			check.OK, check.Detail = false, "will not be applied, not supported by the kernel"
+		}
+
+		d = append(d, check)
+	}
+
+	blkDevs, err := getBlockDevs()
+	blkCheck := Check{"block devices", len(blkDevs) > 0, fmt.Sprintf("%d found, io.max is set on each of them", len(blkDevs))}
+	if err != nil {
+		blkCheck.OK, blkCheck.Detail = false, err.Error()
+	}
+
+	return append(d, blkCheck)
+}
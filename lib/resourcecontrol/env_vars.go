@@ -1,6 +1,54 @@
 package resourcecontrol
 
-import "os"
+import (
+	"errors"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	// reservedEnvPrefix is the prefix of the environment variables used to
+	// pass the limits to the child process, jobs cannot set them
+	reservedEnvPrefix = "OVERSEER_"
+
+	// SafePath is the PATH of the commands unless their policy sets another one
+	SafePath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+)
+
+// EnvPolicy controls the environment the commands start with, the variables of
+// the current process are not passed to them unless they are listed in Inherit
+type EnvPolicy struct {
+	// Inherit lists the names of the variables of the current process passed
+	// to the commands, PATH included
+	Inherit []string
+
+	// Path is the PATH of the commands, SafePath if empty
+	Path string
+}
+
+// environ returns the environment the commands start with in the KEY=VALUE
+// format
+func (p EnvPolicy) environ() []string {
+	path := p.Path
+	if path == "" {
+		path = SafePath
+	}
+
+	evs := []string{(&envVar{"PATH", path}).String()}
+	for _, name := range p.Inherit {
+		if v, ok := os.LookupEnv(name); ok && !strings.HasPrefix(name, reservedEnvPrefix) {
+			evs = append(evs, (&envVar{name, v}).String())
+		}
+	}
+
+	return evs
+}
+
+var (
+	ErrInvalidEnvVar  = errors.New("invalid environment variable, names cannot be empty or contain '=' and values cannot contain NUL characters")
+	ErrReservedEnvVar = errors.New("environment variables starting with " + reservedEnvPrefix + " are reserved")
+)
 
 type envVar struct {
 	name  string
@@ -11,17 +59,64 @@ func (ev *envVar) String() string {
 	return ev.name + "=" + ev.value
 }
 
+// genLimitsEnvVars renders the given limits to the format of their cgroup
+// files, skipping the ones that are not set
 func genLimitsEnvVars(limits ResourceLimits) []envVar {
-	return []envVar{
-		{cpuMaxEnvVar, limits.CPUMax},
-		{memMaxEnvVar, limits.MemMax},
-		{ioMaxRbpsEnvVar, limits.IOMaxRbps},
-		{ioMaxWbpsEnvVar, limits.IOMaxWbps},
+	var evs []envVar
+
+	for _, ev := range []envVar{
+		{cpuMaxEnvVar, limits.cpuMax()},
+		{cpuWeightEnvVar, formatLimit(limits.CPUWeight)},
+		{cpuSetCPUsEnvVar, limits.CPUSetCPUs},
+		{cpuSetMemsEnvVar, limits.CPUSetMems},
+		{memMaxEnvVar, formatLimit(int64(limits.Memory))},
+		{memHighEnvVar, formatLimit(int64(limits.MemoryHigh))},
+		{swapMaxEnvVar, limits.memorySwapMax()},
+		{ioMaxEnvVar, limits.ioMax()},
+		{ioWeightEnvVar, limits.ioWeight()},
+		{pidsMaxEnvVar, formatLimit(limits.Pids)},
+	} {
+		if ev.value != "" {
+			evs = append(evs, ev)
+		}
 	}
+
+	return evs
 }
 
 func unsetCustomEnvVars() {
-	for _, v := range []string{execEnvVar, cpuMaxEnvVar, memMaxEnvVar, ioMaxRbpsEnvVar, ioMaxWbpsEnvVar} {
+	for _, v := range []string{execEnvVar, cgroupEnvVar, stateDirEnvVar, monitorEnvVar, ioMaxEnvVar} {
 		os.Unsetenv(v)
 	}
+
+	for _, lf := range limitFiles {
+		os.Unsetenv(lf.envVar)
+	}
+}
+
+// ValidateEnv returns an error if any of the given environment variables is
+// malformed or reserved
+func ValidateEnv(env map[string]string) error {
+	for k, v := range env {
+		switch {
+		case k == "" || strings.ContainsAny(k, "=\x00") || strings.ContainsRune(v, 0):
+			return ErrInvalidEnvVar
+		case strings.HasPrefix(k, reservedEnvPrefix):
+			return ErrReservedEnvVar
+		}
+	}
+
+	return nil
+}
+
+// renderEnv returns the given environment variables in the KEY=VALUE format,
+// sorted by name
+func renderEnv(env map[string]string) []string {
+	var evs []string
+	for k, v := range env {
+		evs = append(evs, (&envVar{k, v}).String())
+	}
+	sort.Strings(evs)
+
+	return evs
 }
@@ -0,0 +1,287 @@
+package resourcecontrol
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	// cpuPeriod is the cpu.max period, in microseconds
+	cpuPeriod = 100000
+
+	// minCPU is the lowest CPU limit accepted by the cpu controller (a quota
+	// of 1000 microseconds per period)
+	minCPU MilliCPU = 1000 * 1000 / cpuPeriod
+
+	// maxParsedCPU is the highest CPU amount whose cpu.max quota can be
+	// represented
+	maxParsedCPU MilliCPU = math.MaxInt64 / cpuPeriod
+
+	// minWeight and maxWeight are the bounds of cpu.weight and io.weight
+	minWeight = 1
+	maxWeight = 10000
+)
+
+var (
+	ErrNegativeLimit    = errors.New("resource limits cannot be negative")
+	ErrCPUTooLow        = fmt.Errorf("CPU limit cannot be lower than %s", minCPU)
+	ErrCPUTooHigh       = fmt.Errorf("CPU limit cannot be higher than the %d CPUs of the host", runtime.NumCPU())
+	ErrWeightOutOfRange = fmt.Errorf("weights must be between %d and %d", minWeight, maxWeight)
+	ErrInvalidCPUSet    = errors.New("invalid cpuset list, expected a list like 0-3,5")
+	ErrSwapConflict     = errors.New("swap cannot be both disabled and limited")
+)
+
+var cpuSetRegexp = regexp.MustCompile(`^[0-9]+(-[0-9]+)?(,[0-9]+(-[0-9]+)?)*$`)
+
+var byteUnits = []struct {
+	suffix string
+	size   Bytes
+}{
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+}
+
+// MilliCPU is an amount of CPU time expressed in thousandths of a CPU
+type MilliCPU int64
+
+// ParseMilliCPU parses a CPU amount given either in millicores ("500m"), as a
+// percentage of a CPU ("50%") or as a fraction of CPUs ("0.5")
+func ParseMilliCPU(s string) (MilliCPU, error) {
+	var (
+		v   float64
+		err error
+	)
+
+	switch {
+	case strings.HasSuffix(s, "m"):
+		var m int64
+		m, err = strconv.ParseInt(strings.TrimSuffix(s, "m"), 10, 64)
+		v = float64(m)
+	case strings.HasSuffix(s, "%"):
+		v, err = strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		v *= 10
+	default:
+		v, err = strconv.ParseFloat(s, 64)
+		v *= 1000
+	}
+
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) || v > float64(maxParsedCPU) {
+		return 0, fmt.Errorf("invalid CPU amount %q", s)
+	} else if v < 0 {
+		return 0, ErrNegativeLimit
+	}
+
+	// Rounding up keeps a positive amount under a millicore from becoming
+	// zero, which means no limit, so that Validate rejects it instead
+	return MilliCPU(math.Ceil(v)), nil
+}
+
+// String returns the amount in millicores
+func (c MilliCPU) String() string {
+	return strconv.FormatInt(int64(c), 10) + "m"
+}
+
+// Set parses the given value, allowing MilliCPU to be used as a flag.Value
+func (c *MilliCPU) Set(s string) (err error) {
+	*c, err = ParseMilliCPU(s)
+	return
+}
+
+// Bytes is an amount of bytes
+type Bytes int64
+
+// ParseBytes parses an amount of bytes with an optional K, M, G or T (base
+// 1024) suffix, e.g. "128M"
+func ParseBytes(s string) (Bytes, error) {
+	str, mult := s, Bytes(1)
+
+	for _, u := range byteUnits {
+		if strings.HasSuffix(strings.ToUpper(str), u.suffix) {
+			str, mult = str[:len(str)-len(u.suffix)], u.size
+			break
+		}
+	}
+
+	v, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || v > math.MaxInt64/int64(mult) {
+		return 0, fmt.Errorf("invalid amount of bytes %q", s)
+	} else if v < 0 {
+		return 0, ErrNegativeLimit
+	}
+
+	return Bytes(v) * mult, nil
+}
+
+// String returns the amount using the biggest unit that represents it exactly
+func (b Bytes) String() string {
+	for _, u := range byteUnits {
+		if b != 0 && b%u.size == 0 {
+			return strconv.FormatInt(int64(b/u.size), 10) + u.suffix
+		}
+	}
+
+	return strconv.FormatInt(int64(b), 10)
+}
+
+// Set parses the given value, allowing Bytes to be used as a flag.Value
+func (b *Bytes) Set(s string) (err error) {
+	*b, err = ParseBytes(s)
+	return
+}
+
+// ResourceLimits holds the limits enforced on a command, zero values mean that
+// the corresponding limit is not set
+type ResourceLimits struct {
+	// cpu controller
+	CPU       MilliCPU
+	CPUWeight int64
+
+	// cpuset controller, lists of CPUs and memory nodes such as "0-3,5"
+	CPUSetCPUs string
+	CPUSetMems string
+
+	// memory controller, NoSwap disables the swap usage entirely
+	Memory     Bytes
+	MemoryHigh Bytes
+	SwapMax    Bytes
+	NoSwap     bool
+
+	// io controller
+	IOReadBps   Bytes
+	IOWriteBps  Bytes
+	IOReadIOPS  int64
+	IOWriteIOPS int64
+	IOWeight    int64
+
+	// pids controller
+	Pids int64
+}
+
+// Validate returns an error if any of the limits is out of range
+func (l ResourceLimits) Validate() error {
+	for _, v := range []int64{
+		int64(l.CPU), l.CPUWeight, int64(l.Memory), int64(l.MemoryHigh),
+		int64(l.SwapMax), int64(l.IOReadBps), int64(l.IOWriteBps),
+		l.IOReadIOPS, l.IOWriteIOPS, l.IOWeight, l.Pids,
+	} {
+		if v < 0 {
+			return ErrNegativeLimit
+		}
+	}
+
+	if l.CPU > 0 && l.CPU < minCPU {
+		return ErrCPUTooLow
+	} else if l.CPU > MilliCPU(runtime.NumCPU())*1000 {
+		return ErrCPUTooHigh
+	}
+
+	for _, w := range []int64{l.CPUWeight, l.IOWeight} {
+		if w != 0 && (w < minWeight || w > maxWeight) {
+			return ErrWeightOutOfRange
+		}
+	}
+
+	for _, set := range []string{l.CPUSetCPUs, l.CPUSetMems} {
+		if set != "" && !cpuSetRegexp.MatchString(set) {
+			return ErrInvalidCPUSet
+		}
+	}
+
+	if l.NoSwap && l.SwapMax > 0 {
+		return ErrSwapConflict
+	}
+
+	return nil
+}
+
+// controllers returns the names of the cgroup controllers needed to enforce
+// the limits that are set
+func (l ResourceLimits) controllers() []string {
+	var ctrls []string
+
+	for _, c := range []struct {
+		name   string
+		needed bool
+	}{
+		{"cpu", l.CPU > 0 || l.CPUWeight > 0},
+		{"cpuset", l.CPUSetCPUs != "" || l.CPUSetMems != ""},
+		{"io", l.ioMax() != "" || l.IOWeight > 0},
+		{"memory", l.Memory > 0 || l.MemoryHigh > 0 || l.SwapMax > 0 || l.NoSwap},
+		{"pids", l.Pids > 0},
+	} {
+		if c.needed {
+			ctrls = append(ctrls, c.name)
+		}
+	}
+
+	return ctrls
+}
+
+// formatLimit renders the given value, or returns an empty string if it is
+// not set
+func formatLimit(v int64) string {
+	if v == 0 {
+		return ""
+	}
+
+	return strconv.FormatInt(v, 10)
+}
+
+// cpuMax renders the CPU limit in the cpu.max format, or returns an empty
+// string if it is not set
+func (l ResourceLimits) cpuMax() string {
+	if l.CPU == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%d %d", int64(l.CPU)*cpuPeriod/1000, cpuPeriod)
+}
+
+// memorySwapMax renders the swap limit in the memory.swap.max format, or
+// returns an empty string if it is not set
+func (l ResourceLimits) memorySwapMax() string {
+	if l.NoSwap {
+		return "0"
+	}
+
+	return formatLimit(int64(l.SwapMax))
+}
+
+// ioMax renders the IO limits in the io.max format (without the device
+// numbers), or returns an empty string if none of them is set
+func (l ResourceLimits) ioMax() string {
+	var keys []string
+
+	for _, kv := range []struct {
+		key   string
+		value int64
+	}{
+		{"rbps", int64(l.IOReadBps)},
+		{"wbps", int64(l.IOWriteBps)},
+		{"riops", l.IOReadIOPS},
+		{"wiops", l.IOWriteIOPS},
+	} {
+		if kv.value > 0 {
+			keys = append(keys, kv.key+"="+strconv.FormatInt(kv.value, 10))
+		}
+	}
+
+	return strings.Join(keys, " ")
+}
+
+// ioWeight renders the IO weight in the io.weight format, or returns an empty
+// string if it is not set
+func (l ResourceLimits) ioWeight() string {
+	if l.IOWeight == 0 {
+		return ""
+	}
+
+	return "default " + strconv.FormatInt(l.IOWeight, 10)
+}
@@ -0,0 +1,121 @@
+package resourcecontrol
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseMilliCPU(t *testing.T) {
+	var tests = []struct {
+		in       string
+		expected MilliCPU
+		valid    bool
+	}{
+		{"500m", 500, true},
+		{"0.5", 500, true},
+		{"2", 2000, true},
+		{"25%", 250, true},
+		{"0.0005", 1, true},
+		{"0.01%", 1, true},
+		{"-1", 0, false},
+		{"1.5m", 0, false},
+		{"half", 0, false},
+		{"1e300%", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		v, err := ParseMilliCPU(tt.in)
+		if tt.valid && err != nil {
+			t.Errorf("'%s': unexpected error '%s'", tt.in, err)
+		} else if !tt.valid && err == nil {
+			t.Errorf("'%s': expected an error, got '%s'", tt.in, v)
+		} else if v != tt.expected {
+			t.Errorf("'%s': expected '%s', got '%s'", tt.in, tt.expected, v)
+		}
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	var tests = []struct {
+		in       string
+		expected Bytes
+		valid    bool
+	}{
+		{"1024", 1024, true},
+		{"128M", 128 << 20, true},
+		{"1g", 1 << 30, true},
+		{"4K", 4096, true},
+		{"128MB", 0, false},
+		{"-1", 0, false},
+		{"M", 0, false},
+		{"99999999999T", 0, false},
+	}
+
+	for _, tt := range tests {
+		v, err := ParseBytes(tt.in)
+		if tt.valid && err != nil {
+			t.Errorf("'%s': unexpected error '%s'", tt.in, err)
+		} else if !tt.valid && err == nil {
+			t.Errorf("'%s': expected an error, got '%s'", tt.in, v)
+		} else if v != tt.expected {
+			t.Errorf("'%s': expected '%s', got '%s'", tt.in, tt.expected, v)
+		}
+	}
+}
+
+func TestRenderLimits(t *testing.T) {
+	limits := ResourceLimits{
+		CPU:        150,
+		Memory:     64 << 20,
+		IOWriteBps: 5 << 20,
+		IOReadIOPS: 100,
+	}
+
+	if err := limits.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct{ expected, got string }{
+		{"15000 100000", limits.cpuMax()},
+		{"67108864", formatLimit(int64(limits.Memory))},
+		{"wbps=5242880 riops=100", limits.ioMax()},
+		{"", limits.memorySwapMax()},
+		{"0", ResourceLimits{NoSwap: true}.memorySwapMax()},
+		{"default 300", ResourceLimits{IOWeight: 300}.ioWeight()},
+		{"cpu io memory", strings.Join(limits.controllers(), " ")},
+	} {
+		if tt.got != tt.expected {
+			t.Errorf("expected '%s', got '%s'", tt.expected, tt.got)
+		}
+	}
+
+	for _, tt := range []struct {
+		limits   ResourceLimits
+		expected error
+	}{
+		{ResourceLimits{CPU: 5}, ErrCPUTooLow},
+		{ResourceLimits{CPU: mustParseMilliCPU(t, "0.0009")}, ErrCPUTooLow},
+		{ResourceLimits{CPU: MilliCPU(runtime.NumCPU()*1000 + 1)}, ErrCPUTooHigh},
+		{ResourceLimits{CPUWeight: 10001}, ErrWeightOutOfRange},
+		{ResourceLimits{IOWeight: 100, Pids: -1}, ErrNegativeLimit},
+		{ResourceLimits{CPUSetCPUs: "0-3,a"}, ErrInvalidCPUSet},
+		{ResourceLimits{NoSwap: true, SwapMax: 1}, ErrSwapConflict},
+		{ResourceLimits{CPUSetCPUs: "0-3,5", CPUSetMems: "0", Pids: 64}, nil},
+	} {
+		if err := tt.limits.Validate(); err != tt.expected {
+			t.Errorf("expected '%v', got '%v'", tt.expected, err)
+		}
+	}
+}
+
+// mustParseMilliCPU parses the given CPU amount, failing the test on error
+func mustParseMilliCPU(t *testing.T, s string) MilliCPU {
+	v, err := ParseMilliCPU(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
@@ -0,0 +1,364 @@
+package resourcecontrol
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// pidFile and exitFile are written to the state directory of a command
+	// by its monitor, holding the PID of the command and its wait status,
+	// and startFile holds the start time of the monitor, which tells it
+	// apart from a process that reused its PID
+	pidFile   = "pid"
+	exitFile  = "exit"
+	startFile = "start"
+
+	// monitorPollInterval is how often a recovered command is checked while
+	// waiting for it
+	monitorPollInterval = 100 * time.Millisecond
+)
+
+var (
+	ErrStateDirWithTTY = errors.New("a command with a terminal cannot outlive the current process")
+	ErrNoPID           = errors.New("the PID of the command was not recorded")
+
+	errInvalidStat = errors.New("invalid process stat")
+)
+
+// ExitError is returned by Wait when the main process of a command started
+// with a state directory did not exit successfully
+type ExitError struct {
+	WaitStatus syscall.WaitStatus
+}
+
+func (e *ExitError) Error() string {
+	ws := e.WaitStatus
+
+	switch {
+	case ws.Exited():
+		return "exit status " + strconv.Itoa(ws.ExitStatus())
+	case ws.Signaled() && ws.CoreDump():
+		return "signal: " + ws.Signal().String() + " (core dumped)"
+	case ws.Signaled():
+		return "signal: " + ws.Signal().String()
+	}
+
+	return "wait status " + strconv.Itoa(int(ws))
+}
+
+// writeFileAtomic writes the given value to a file in the given directory,
+// readers find either the whole value or no file at all
+func writeFileAtomic(dir, name, value string) error {
+	tmpPath := path.Join(dir, "."+name)
+	if err := os.WriteFile(tmpPath, []byte(value), 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path.Join(dir, name))
+}
+
+// readIntFile parses the integer held by the given file of the directory
+func readIntFile(dir, name string) (int, error) {
+	out, err := os.ReadFile(path.Join(dir, name))
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(string(bytes.TrimSpace(out)))
+}
+
+// runMonitor starts the command given in the arguments as a child of the
+// current process, which moves to the cgroup at monitorPath as its monitor,
+// records its PID and, once it exits, its wait status in the state directory.
+// The child is the current executable again, which joins the cgroup at
+// cgroupPath before running the command, so only the command counts against
+// its limits. It never returns.
+func runMonitor(errPipe *os.File, stateDir, monitorPath, cgroupPath string) {
+	if monitorPath == "" {
+		writeAndDie(errPipe, errNoCgroup)
+	}
+
+	if err := joinCgroup(monitorPath); err != nil {
+		writeAndDie(errPipe, err)
+	}
+
+	// The signals sent to the process group of the command, such as by the
+	// command itself, are caught, ignoring them would make the command
+	// inherit them
+	signal.Notify(make(chan os.Signal, 1))
+
+	// Otherwise the command would hold the pipe open until it exits
+	unix.CloseOnExec(errPipeFd)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		writeAndDie(errPipe, err)
+	}
+
+	cmd := &exec.Cmd{
+		Path:       "/proc/self/exe",
+		Args:       os.Args,
+		Env:        append(os.Environ(), execEnvVar+"="+execEnvVar, cgroupEnvVar+"="+cgroupPath),
+		Stdin:      os.Stdin,
+		Stdout:     os.Stdout,
+		Stderr:     os.Stderr,
+		ExtraFiles: []*os.File{w},
+	}
+
+	if err := cmd.Start(); err != nil {
+		writeAndDie(errPipe, err)
+	}
+	w.Close()
+
+	// The errors of the child, such as a command not found, are relayed
+	if out, err := io.ReadAll(r); err != nil || len(out) > 0 {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+
+		if err == nil {
+			err = errors.New(string(out))
+		}
+		writeAndDie(errPipe, err)
+	}
+	r.Close()
+
+	_, start, err := processStat(os.Getpid())
+	if err == nil {
+		err = writeFileAtomic(stateDir, startFile, strconv.FormatUint(start, 10))
+	}
+	if err == nil {
+		err = writeFileAtomic(stateDir, pidFile, strconv.Itoa(cmd.Process.Pid))
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+		writeAndDie(errPipe, err)
+	}
+
+	// Closing the pipe tells the parent process that the command started
+	errPipe.Close()
+	os.Stdin.Close()
+	os.Stdout.Close()
+	os.Stderr.Close()
+
+	// The wait status is recorded whether the command exited successfully
+	// or not
+	_ = cmd.Wait()
+	ws := cmd.ProcessState.Sys().(syscall.WaitStatus)
+
+	if err := writeFileAtomic(stateDir, exitFile, strconv.Itoa(int(ws))); err != nil {
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+// processStat returns the state of the process with the given PID, such as "Z"
+// for a zombie, and the time it started at, in clock ticks since boot, which
+// are the 3rd and 22nd fields of /proc/<pid>/stat
+func processStat(pid int) (state string, start uint64, err error) {
+	out, err := os.ReadFile(path.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return "", 0, err
+	}
+
+	// The second field is the command name in parentheses, which might hold
+	// spaces and parentheses itself
+	i := bytes.LastIndexByte(out, ')')
+	if i < 0 {
+		return "", 0, errInvalidStat
+	}
+
+	fields := strings.Fields(string(out[i+1:]))
+	if len(fields) < 20 {
+		return "", 0, errInvalidStat
+	}
+
+	start, err = strconv.ParseUint(fields[19], 10, 64)
+	return fields[0], start, err
+}
+
+// readWaitStatus returns the wait status recorded by the monitor of a command
+// in its state directory, and false if there is none
+func readWaitStatus(stateDir string) (syscall.WaitStatus, bool) {
+	v, err := readIntFile(stateDir, exitFile)
+	if err != nil {
+		return 0, false
+	}
+
+	return syscall.WaitStatus(v), true
+}
+
+// Recover returns a command started with a state directory by another
+// process, usually a previous instance of the current one, given the directory,
+// the name of the cgroup the command runs in, whether it joined the cgroup of
+// another command, and the PID of its monitor. The command can be waited for,
+// signalled and killed as if it had been started by the current process, its
+// output is only available from the files it writes to.
+func Recover(stateDir, cgroupName string, joined bool, monitorPID int) (*Cmd, error) {
+	pid, err := readIntFile(stateDir, pidFile)
+	if err != nil {
+		return nil, ErrNoPID
+	}
+
+	cgroup, err := existingCgroup(cgroupName)
+	if err != nil {
+		return nil, err
+	}
+
+	// The start time is recorded before the PID
+	start, err := readIntFile(stateDir, startFile)
+	if err != nil {
+		return nil, ErrNoPID
+	}
+
+	monitor, err := os.FindProcess(monitorPID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cmd{
+		Cmd:          &exec.Cmd{Process: monitor},
+		CgroupName:   cgroupName,
+		StateDir:     stateDir,
+		cgroup:       cgroup,
+		joined:       joined,
+		pid:          pid,
+		recovered:    true,
+		monitorStart: uint64(start),
+		exited:       make(chan struct{}),
+	}, nil
+}
+
+// Discard kills the processes left in the cgroup with the given name by a
+// command started by another process that cannot be recovered, such as one
+// with a terminal or whose PID was not recorded, then removes the cgroup
+func Discard(cgroupName string) error {
+	cgroup, err := existingCgroup(cgroupName)
+	if err != nil {
+		return err
+	}
+
+	if err := cgroup.Kill(); err != nil {
+		return err
+	}
+
+	if err := cgroup.Remove(); err != nil && !isRemoved(err) {
+		return err
+	}
+
+	return nil
+}
+
+// waitRecovered blocks until the monitor of a recovered command has exited,
+// it is no longer a child of the current process so its PID is polled
+func (c *Cmd) waitRecovered() {
+	for {
+		// The monitor is done once it has recorded the wait status
+		if _, ok := readWaitStatus(c.StateDir); ok {
+			return
+		}
+
+		// A process with the PID of the monitor that started at another
+		// time reused it after the monitor exited, which might also be
+		// left as a zombie until its parent reaps it
+		state, start, err := processStat(c.Process.Pid)
+		if err != nil || start != c.monitorStart || state == "Z" {
+			return
+		}
+
+		time.Sleep(monitorPollInterval)
+	}
+}
+
+// waitMonitor waits for the monitor of the command to exit and returns the
+// wait status of the command, an ExitError if it did not exit successfully
+func (c *Cmd) waitMonitor() error {
+	var err error
+	if c.recovered {
+		c.waitRecovered()
+	} else {
+		err = c.Cmd.Wait()
+	}
+
+	ws, ok := readWaitStatus(c.StateDir)
+	switch {
+	case ok:
+	case c.ProcessState != nil:
+		// The monitor was killed before recording the command
+		ws = c.ProcessState.Sys().(syscall.WaitStatus)
+	case err != nil:
+		return err
+	default:
+		// Only SIGKILL stops the monitor before it records the command
+		ws = syscall.WaitStatus(unix.SIGKILL)
+	}
+	c.waitStatus = &ws
+
+	if ws.Exited() && ws.ExitStatus() == 0 {
+		return nil
+	}
+
+	return &ExitError{WaitStatus: ws}
+}
+
+// WaitStatus returns the wait status of the main process of the command, it is
+// only available after Wait has returned
+func (c *Cmd) WaitStatus() (syscall.WaitStatus, bool) {
+	if c.waitStatus != nil {
+		return *c.waitStatus, true
+	}
+
+	if c.ProcessState == nil {
+		return 0, false
+	}
+
+	ws, ok := c.ProcessState.Sys().(syscall.WaitStatus)
+	return ws, ok
+}
+
+// Pid returns the PID of the main process of the command, which is not the one
+// of Process when the command runs under a monitor
+func (c *Cmd) Pid() int {
+	if c.pid != 0 {
+		return c.pid
+	}
+
+	return c.Process.Pid
+}
+
+// MonitorPID returns the PID of the monitor of a command started with a state
+// directory, which is needed to recover it
+func (c *Cmd) MonitorPID() int {
+	if c.StateDir == "" {
+		return 0
+	}
+
+	return c.Process.Pid
+}
+
+// SignalProcess sends the given signal to the main process of the command
+func (c *Cmd) SignalProcess(sig unix.Signal) error {
+	if c.pid == 0 {
+		return c.Process.Signal(sig)
+	}
+
+	if err := unix.Kill(c.pid, sig); err == unix.ESRCH {
+		return os.ErrProcessDone
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
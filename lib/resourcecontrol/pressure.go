@@ -0,0 +1,80 @@
+package resourcecontrol
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// PressureStats holds a line of a pressure stall information (PSI) file: the
+// share of time, as a percentage, some or all of the tasks of the cgroup were
+// stalled over the last 10, 60 and 300 seconds, and the total stall time
+type PressureStats struct {
+	Avg10     float64
+	Avg60     float64
+	Avg300    float64
+	TotalUsec int64
+}
+
+// Pressure holds the contents of a PSI file, the "full" line is always empty
+// for the CPU on older kernels
+type Pressure struct {
+	Some PressureStats
+	Full PressureStats
+}
+
+// Pressure returns the pressure stall information of the given resource for
+// the cgroup, one of "cpu", "memory" or "io". An error satisfying
+// os.IsNotExist is returned if the kernel does not support PSI.
+func (c *Cgroup) Pressure(resource string) (Pressure, error) {
+	out, err := os.ReadFile(path.Join(c.path, resource+".pressure"))
+	if err != nil {
+		return Pressure{}, err
+	}
+
+	var p Pressure
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		fs := strings.Fields(sc.Text())
+		if len(fs) == 0 {
+			continue
+		}
+
+		var ps *PressureStats
+		switch fs[0] {
+		case "some":
+			ps = &p.Some
+		case "full":
+			ps = &p.Full
+		default:
+			continue
+		}
+
+		for _, f := range fs[1:] {
+			kv := strings.SplitN(f, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+
+			switch kv[0] {
+			case "avg10":
+				ps.Avg10, err = strconv.ParseFloat(kv[1], 64)
+			case "avg60":
+				ps.Avg60, err = strconv.ParseFloat(kv[1], 64)
+			case "avg300":
+				ps.Avg300, err = strconv.ParseFloat(kv[1], 64)
+			case "total":
+				ps.TotalUsec, err = strconv.ParseInt(kv[1], 10, 64)
+			}
+
+			if err != nil {
+				return Pressure{}, err
+			}
+		}
+	}
+
+	return p, sc.Err()
+}
@@ -0,0 +1,87 @@
+package resourcecontrol
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// Terminal is the master side of the pseudo-terminal of a command, writing to
+// it sends input to the command
+type Terminal struct {
+	master *os.File
+}
+
+// openPTY allocates a new pseudo-terminal, returning its master and slave sides
+func openPTY() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var n int
+	if err := control(master, func(fd int) (err error) {
+		if err = unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err == nil {
+			n, err = unix.IoctlGetInt(fd, unix.TIOCGPTN)
+		}
+		return
+	}); err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+
+	slave, err := os.OpenFile("/dev/pts/"+strconv.Itoa(n), os.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+
+	return master, slave, nil
+}
+
+// control runs fn on the file descriptor of f, unlike f.Fd() it does not
+// switch the file to blocking mode, so closing it still interrupts its reads
+func control(f *os.File, fn func(fd int) error) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var fnErr error
+	if err := rc.Control(func(fd uintptr) {
+		fnErr = fn(int(fd))
+	}); err != nil {
+		return err
+	}
+
+	return fnErr
+}
+
+// copyPTY copies the output of the pseudo-terminal to w until every process
+// holding its slave side has closed it
+func copyPTY(w io.Writer, master *os.File) error {
+	_, err := io.Copy(w, master)
+
+	// The master returns EIO once the slave side is closed
+	if errors.Is(err, unix.EIO) || errors.Is(err, os.ErrClosed) {
+		err = nil
+	}
+
+	return err
+}
+
+// Write sends the given input to the command
+func (t *Terminal) Write(p []byte) (int, error) {
+	return t.master.Write(p)
+}
+
+// Resize sets the window size of the terminal, the foreground process group of
+// the command gets a SIGWINCH if it changed
+func (t *Terminal) Resize(rows, cols uint16) error {
+	return control(t.master, func(fd int) error {
+		return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, &unix.Winsize{Row: rows, Col: cols})
+	})
+}
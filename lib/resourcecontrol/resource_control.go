@@ -19,30 +19,50 @@ import (
 // user will not be affected.
 
 const (
-	execEnvVar      = "OVERSEER_EXEC"
-	cpuMaxEnvVar    = "OVERSEER_CPU_MAX"
-	memMaxEnvVar    = "OVERSEER_MEM_MAX"
-	ioMaxRbpsEnvVar = "OVERSEER_IO_MAX_RBPS"
-	ioMaxWbpsEnvVar = "OVERSEER_IO_MAX_WBPS"
+	execEnvVar       = "OVERSEER_EXEC"
+	cpuMaxEnvVar     = "OVERSEER_CPU_MAX"
+	cpuWeightEnvVar  = "OVERSEER_CPU_WEIGHT"
+	cpuSetCPUsEnvVar = "OVERSEER_CPUSET_CPUS"
+	cpuSetMemsEnvVar = "OVERSEER_CPUSET_MEMS"
+	memMaxEnvVar     = "OVERSEER_MEM_MAX"
+	memHighEnvVar    = "OVERSEER_MEM_HIGH"
+	swapMaxEnvVar    = "OVERSEER_SWAP_MAX"
+	ioMaxEnvVar      = "OVERSEER_IO_MAX"
+	ioWeightEnvVar   = "OVERSEER_IO_WEIGHT"
+	pidsMaxEnvVar    = "OVERSEER_PIDS_MAX"
+	cgroupEnvVar     = "OVERSEER_CGROUP"
+	stateDirEnvVar   = "OVERSEER_STATE_DIR"
+	monitorEnvVar    = "OVERSEER_MONITOR_CGROUP"
 
 	controlSubtree = "overseer"
 
 	errPipeFd = 3
 )
 
+// limitFiles maps the environment variables holding the rendered limits to
+// the cgroup files they are written to, io.max is handled separately since it
+// needs to be written once per block device
+var limitFiles = []struct {
+	envVar string
+	file   string
+}{
+	{cpuSetCPUsEnvVar, "cpuset.cpus"},
+	{cpuSetMemsEnvVar, "cpuset.mems"},
+	{cpuMaxEnvVar, "cpu.max"},
+	{cpuWeightEnvVar, "cpu.weight"},
+	{memHighEnvVar, "memory.high"},
+	{memMaxEnvVar, "memory.max"},
+	{swapMaxEnvVar, "memory.swap.max"},
+	{ioWeightEnvVar, "io.weight"},
+	{pidsMaxEnvVar, "pids.max"},
+}
+
 var (
 	errCgroupNotMounted = errors.New("cgroup2 is not mounted")
 	errNotEnoughArgs    = errors.New("not enough arguments provided")
+	errNoCgroup         = errors.New("no cgroup provided")
 )
 
-// TODO: add more limits
-type ResourceLimits struct {
-	CPUMax    string
-	MemMax    string
-	IOMaxRbps string
-	IOMaxWbps string
-}
-
 func writeAndDie(f *os.File, m error) {
 	if _, err := f.Write([]byte(m.Error())); err != nil {
 		log.Fatal(err)
@@ -66,18 +86,33 @@ func init() {
 		log.Fatal("pipe not found")
 	}
 
-	if err := setResourceLimits(); err != nil {
-		writeAndDie(errPipe, err)
+	cgroupPath := os.Getenv(cgroupEnvVar)
+	if cgroupPath == "" {
+		writeAndDie(errPipe, errNoCgroup)
 	}
 
-	// TODO: drop privileges
+	if err := setResourceLimits(cgroupPath); err != nil {
+		writeAndDie(errPipe, err)
+	}
 
+	stateDir, monitorPath := os.Getenv(stateDirEnvVar), os.Getenv(monitorEnvVar)
 	unsetCustomEnvVars()
 
 	if len(os.Args) < 2 {
 		writeAndDie(errPipe, errNotEnoughArgs)
 	}
 
+	// The monitor stays out of the cgroup, the command it starts joins it
+	if stateDir != "" {
+		runMonitor(errPipe, stateDir, monitorPath, cgroupPath)
+	}
+
+	if err := joinCgroup(cgroupPath); err != nil {
+		writeAndDie(errPipe, err)
+	}
+
+	// TODO: drop privileges
+
 	execPath, err := exec.LookPath(os.Args[1])
 	if err != nil {
 		writeAndDie(errPipe, err)
@@ -89,16 +124,18 @@ func init() {
 	}
 }
 
-func getCgroupRootPath() (string, error) {
+// findMountPoint returns the mount point of the first filesystem of the given
+// type listed in /proc/self/mounts, or an empty string if there is none
+func findMountPoint(fsType string) (string, error) {
 	r, err := os.Open("/proc/self/mounts")
 	if err != nil {
 		return "", err
 	}
 	defer r.Close()
 
-	var mountType, path, d string
+	var mountPoint, mountType, d string
 	for eof := false; !eof; {
-		_, err := fmt.Fscanf(r, "%s %s %s %s %s %s\n", &mountType, &path, &d, &d, &d, &d)
+		_, err := fmt.Fscanf(r, "%s %s %s %s %s %s\n", &d, &mountPoint, &mountType, &d, &d, &d)
 
 		if err == io.EOF {
 			eof = true
@@ -106,14 +143,33 @@ func getCgroupRootPath() (string, error) {
 			return "", err
 		}
 
-		if mountType == "cgroup2" {
-			return path, nil
+		if mountType == fsType {
+			return mountPoint, nil
 		}
 	}
 
-	// TODO: try to mount it before giving up
+	return "", nil
+}
+
+// getCgroupRootPath returns the mount point of the cgroup2 filesystem, trying
+// to mount it if it is not mounted yet
+func getCgroupRootPath() (string, error) {
+	mountPoint, err := findMountPoint("cgroup2")
+	if err != nil {
+		return "", err
+	} else if mountPoint != "" {
+		return mountPoint, nil
+	}
+
+	if err := mountCgroup(); err != nil {
+		return "", fmt.Errorf("%s: %w", errCgroupNotMounted, err)
+	}
+
+	if mountPoint, err = findMountPoint("cgroup2"); err == nil && mountPoint == "" {
+		err = errCgroupNotMounted
+	}
 
-	return "", errCgroupNotMounted
+	return mountPoint, err
 }
 
 func getBlockDevs() ([]string, error) {
@@ -136,72 +192,58 @@ func getBlockDevs() ([]string, error) {
 	return rs, nil
 }
 
-func setResourceLimits() error {
-	cgroupPath, err := getCgroupRootPath()
-	if err != nil {
-		return err
-	}
-
-	cgroupPath = path.Join(cgroupPath, controlSubtree)
-
-	if err := os.Mkdir(cgroupPath, 0755); err != nil && !os.IsExist(err) {
-		return err
-	}
-
-	// Set CPU limits
-	if v, ok := os.LookupEnv(cpuMaxEnvVar); ok {
-		if err := os.WriteFile(path.Join(cgroupPath, "cpu.max"), []byte(v), 0700); err != nil {
-			return err
-		}
-	}
-
-	// Set memory limits
-	if v, ok := os.LookupEnv(memMaxEnvVar); ok {
-		if err := os.WriteFile(path.Join(cgroupPath, "memory.max"), []byte(v), 0700); err != nil {
-			return err
+// setResourceLimits writes the limits found in the environment variables to
+// the cgroup at the given path
+func setResourceLimits(cgroupPath string) error {
+	for _, lf := range limitFiles {
+		if v, ok := os.LookupEnv(lf.envVar); ok {
+			if err := os.WriteFile(path.Join(cgroupPath, lf.file), []byte(v), 0700); err != nil {
+				return err
+			}
 		}
 	}
 
 	// Set IO limits
-	ioLimitString := ""
-	if v, ok := os.LookupEnv(ioMaxRbpsEnvVar); ok {
-		ioLimitString += " rbps=" + v
-	}
-
-	if v, ok := os.LookupEnv(ioMaxWbpsEnvVar); ok {
-		ioLimitString += " wbps=" + v
-	}
-
-	if ioLimitString != "" {
+	if v, ok := os.LookupEnv(ioMaxEnvVar); ok {
 		blkMajorMinors, err := getBlockDevs()
 		if err != nil {
 			return err
 		}
 
 		for _, b := range blkMajorMinors {
-			if err := os.WriteFile(path.Join(cgroupPath, "io.max"), []byte(b+ioLimitString), 0700); err != nil {
+			if err := os.WriteFile(path.Join(cgroupPath, "io.max"), []byte(b+" "+v), 0700); err != nil {
 				return err
 			}
 		}
 	}
 
-	// Add own PID to the cgroup
-	if err := os.WriteFile(path.Join(cgroupPath, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0700); err != nil {
-		return err
-	}
-
 	return nil
 }
 
+// joinCgroup moves the current process to the cgroup at the given path
+func joinCgroup(cgroupPath string) error {
+	return os.WriteFile(path.Join(cgroupPath, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0700)
+}
+
 // Command takes the given name and args and returns a command with resource
-// limits enforced
+// limits enforced, the command will run in its own leaf cgroup. The limits are
+// validated when the command is started. The environment of the command is
+// built from its EnvPolicy, then SetEnv adds to it, and its working directory
+// can be set through Dir like with exec.Cmd.
 func Command(limits ResourceLimits, name string, args ...string) *Cmd {
 	cmd := exec.Command("/proc/self/exe", append([]string{name}, args...)...)
-	cmd.Env = append(os.Environ(), execEnvVar+"="+execEnvVar)
+	cmd.Env = []string{execEnvVar + "=" + execEnvVar}
 
-	for _, ev := range genLimitsEnvVars(limits) {
-		cmd.Env = append(cmd.Env, ev.String())
-	}
+	return &Cmd{Cmd: cmd, limits: limits}
+}
+
+// CommandIn returns a command that will run in the given existing cgroup,
+// usually the one of another command, sharing its resource limits. The cgroup
+// is neither limited nor removed by the returned command, and its other
+// processes are left alone when the command is waited for or killed.
+func CommandIn(cgroup *Cgroup, name string, args ...string) *Cmd {
+	cmd := Command(ResourceLimits{}, name, args...)
+	cmd.cgroup, cmd.joined = cgroup, true
 
-	return &Cmd{cmd}
+	return cmd
 }
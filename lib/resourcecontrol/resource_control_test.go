@@ -4,100 +4,313 @@ import (
 	"bytes"
 	"os"
 	"path"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSetResourceLimits(t *testing.T) {
-	cgroupPath, err := getCgroupRootPath()
+	var tests = []struct {
+		limits         ResourceLimits
+		expectedCPUMax string
+		expectedMemMax string
+	}{
+		{
+			ResourceLimits{CPU: 200, Memory: 8 << 20, IOReadBps: 1111, IOWriteBps: 3333},
+			"20000 100000",
+			"8388608",
+		},
+		{
+			ResourceLimits{},
+			"max 100000",
+			"max",
+		},
+	}
+
+	for _, tt := range tests {
+		cgroup, err := newCgroup("test-set-resource-limits", tt.limits.controllers())
+		if err != nil {
+			t.Fatal(err)
+		}
+		cgroupPath := cgroup.Path()
+
+		unsetCustomEnvVars()
+		for _, ev := range genLimitsEnvVars(tt.limits) {
+			os.Setenv(ev.name, ev.value)
+		}
+
+		if err := setResourceLimits(cgroupPath); err != nil {
+			cgroup.Remove()
+			t.Fatal(err)
+		}
+
+		out, err := os.ReadFile(path.Join(cgroupPath, "cpu.max"))
+		out = bytes.TrimSpace(out)
+		if err != nil {
+			t.Error(err)
+		} else if !bytes.Equal(out, []byte(tt.expectedCPUMax)) {
+			t.Errorf("expected '%s', got '%s'", tt.expectedCPUMax, string(out))
+		}
+
+		out, err = os.ReadFile(path.Join(cgroupPath, "memory.max"))
+		out = bytes.TrimSpace(out)
+		if err != nil {
+			t.Error(err)
+		} else if !bytes.Equal(out, []byte(tt.expectedMemMax)) {
+			t.Errorf("expected '%s', got '%s'", tt.expectedMemMax, string(out))
+		}
+
+		// TODO: test IO limits
+
+		if err := cgroup.Remove(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestInvalidLimits(t *testing.T) {
+	cmd := Command(ResourceLimits{Memory: -1}, "echo")
+
+	if err := cmd.Start(); err != ErrNegativeLimit {
+		t.Errorf("expected '%s', got '%v'", ErrNegativeLimit, err)
+	} else if cmd.Process != nil {
+		t.Error("the process should not have been started")
+	}
+}
+
+func TestInvalidEnv(t *testing.T) {
+	var tests = []struct {
+		env      map[string]string
+		expected error
+	}{
+		{map[string]string{"FOO": "bar", "EMPTY": ""}, nil},
+		{map[string]string{"": "bar"}, ErrInvalidEnvVar},
+		{map[string]string{"FOO=BAR": "baz"}, ErrInvalidEnvVar},
+		{map[string]string{"FOO": "b\x00r"}, ErrInvalidEnvVar},
+		{map[string]string{cgroupEnvVar: "/"}, ErrReservedEnvVar},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateEnv(tt.env); err != tt.expected {
+			t.Errorf("%v: expected '%v', got '%v'", tt.env, tt.expected, err)
+		}
+	}
+}
+
+func TestEnvAndDir(t *testing.T) {
+	cmd := Command(ResourceLimits{}, "sh", "-c", "echo $FOO $HOME; pwd")
+	cmd.SetEnv(map[string]string{"FOO": "bar", "HOME": "/nonexistent"})
+	cmd.Dir = "/tmp"
+
+	out, err := cmd.Output()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	limits := ResourceLimits{
-		CPUMax:    "20000 100000",
-		MemMax:    "8388608",
-		IOMaxRbps: "1111",
-		IOMaxWbps: "3333",
+	expected := "bar /nonexistent\n/tmp"
+	if out := string(bytes.TrimSpace(out)); out != expected {
+		t.Errorf("expected '%s', got '%s'", expected, out)
 	}
+}
 
-	for _, ev := range genLimitsEnvVars(limits) {
-		os.Setenv(ev.name, ev.value)
+func TestEnvPolicy(t *testing.T) {
+	os.Setenv("OVERSEER_TEST_INHERITED", "inherited")
+	os.Setenv("OVERSEER_TEST_SECRET", "secret")
+	os.Setenv("TEST_INHERITED", "inherited")
+	os.Setenv("TEST_SECRET", "secret")
+	defer func() {
+		for _, name := range []string{"OVERSEER_TEST_INHERITED", "OVERSEER_TEST_SECRET", "TEST_INHERITED", "TEST_SECRET"} {
+			os.Unsetenv(name)
+		}
+	}()
+
+	var tests = []struct {
+		policy   EnvPolicy
+		expected string
+	}{
+		{EnvPolicy{}, SafePath + ":::"},
+		{EnvPolicy{Inherit: []string{"TEST_INHERITED", "OVERSEER_TEST_INHERITED"}}, SafePath + ":inherited::"},
+		{EnvPolicy{Path: "/bin"}, "/bin:::"},
 	}
 
-	if err := setResourceLimits(); err != nil {
-		t.Fatal(err)
+	for _, tt := range tests {
+		cmd := Command(ResourceLimits{}, "sh", "-c", "echo $PATH:$TEST_INHERITED:$TEST_SECRET:$OVERSEER_TEST_INHERITED")
+		cmd.EnvPolicy = tt.policy
+
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if out := string(bytes.TrimSpace(out)); out != tt.expected {
+			t.Errorf("expected '%s', got '%s'", tt.expected, out)
+		}
 	}
+}
 
-	out, err := os.ReadFile(path.Join(cgroupPath, controlSubtree, "cpu.max"))
-	out = bytes.TrimSpace(out)
+func TestEcho(t *testing.T) {
+	limits := ResourceLimits{}
+
+	helloString := "hello world"
+	cmd := Command(limits, "echo", helloString)
+
+	out, err := cmd.Output()
 	if err != nil {
-		t.Error(err)
-	} else if !bytes.Equal(out, []byte(limits.CPUMax)) {
-		t.Errorf("expected '%s', got '%s'", limits.CPUMax, string(out))
+		t.Fatal(err)
 	}
-
-	out, err = os.ReadFile(path.Join(cgroupPath, controlSubtree, "memory.max"))
 	out = bytes.TrimSpace(out)
-	if err != nil {
-		t.Error(err)
-	} else if !bytes.Equal(out, []byte(limits.MemMax)) {
-		t.Errorf("expected '%s', got '%s'", limits.MemMax, string(out))
+
+	if !bytes.Equal(out, []byte(helloString)) {
+		t.Errorf("expected '%s', got '%s'", helloString, string(out))
 	}
+}
 
-	// TODO: test IO limits
+func TestDiagnose(t *testing.T) {
+	diagnosis := Diagnose()
 
-	limits = ResourceLimits{
-		CPUMax:    "max 100000",
-		MemMax:    "max",
-		IOMaxRbps: "max",
-		IOMaxWbps: "max",
+	if len(diagnosis) == 0 {
+		t.Fatal("expected at least one check")
+	} else if !diagnosis[0].OK {
+		t.Errorf("cgroup2 mount check failed: %s", diagnosis[0].Detail)
 	}
 
-	for _, ev := range genLimitsEnvVars(limits) {
-		os.Setenv(ev.name, ev.value)
+	if diagnosis = append(diagnosis, Check{Name: "failed", OK: false}); diagnosis.OK() {
+		t.Error("a diagnosis with failed checks should not be OK")
 	}
+}
+
+func TestTTY(t *testing.T) {
+	var out bytes.Buffer
+
+	// The input is only sent after resizing, so the size is known to be set
+	cmd := Command(ResourceLimits{}, "sh", "-c", "read l; stty size; tty")
+	cmd.TTY = true
+	cmd.Stdout = &out
 
-	if err := setResourceLimits(); err != nil {
+	if err := cmd.Start(); err != nil {
 		t.Fatal(err)
 	}
 
-	out, err = os.ReadFile(path.Join(cgroupPath, controlSubtree, "cpu.max"))
-	out = bytes.TrimSpace(out)
-	if err != nil {
-		t.Error(err)
-	} else if !bytes.Equal(out, []byte(limits.CPUMax)) {
-		t.Errorf("expected '%s', got '%s'", limits.CPUMax, string(out))
+	if err := cmd.Terminal().Resize(30, 100); err != nil {
+		t.Fatal(err)
 	}
 
-	out, err = os.ReadFile(path.Join(cgroupPath, controlSubtree, "memory.max"))
-	out = bytes.TrimSpace(out)
-	if err != nil {
-		t.Error(err)
-	} else if !bytes.Equal(out, []byte(limits.MemMax)) {
-		t.Errorf("expected '%s', got '%s'", limits.MemMax, string(out))
+	if _, err := cmd.Terminal().Write([]byte("x\n")); err != nil {
+		t.Fatal(err)
 	}
 
-	// TODO: test IO limits
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "x\r\n30 100\r\n/dev/pts/"
+	if !strings.HasPrefix(out.String(), expected) {
+		t.Errorf("expected '%s...', got '%s'", expected, out.String())
+	}
 }
 
-func TestEcho(t *testing.T) {
-	limits := ResourceLimits{
-		CPUMax:    "max 100000",
-		MemMax:    "max",
-		IOMaxRbps: "max",
-		IOMaxWbps: "max",
+func TestCommandIn(t *testing.T) {
+	cmd := Command(ResourceLimits{}, "sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
 	}
+	defer cmd.Wait()
+	defer cmd.Kill()
 
-	helloString := "hello world"
-	cmd := Command(limits, "echo", helloString)
+	out, err := CommandIn(cmd.Cgroup(), "cat", "/proc/self/cgroup").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasSuffix(bytes.TrimSpace(out), []byte("/"+cmd.CgroupName)) {
+		t.Errorf("expected '.../%s', got '%s'", cmd.CgroupName, out)
+	}
+
+	// The processes of the command owning the cgroup are left alone
+	if populated, err := cmd.Cgroup().Populated(); err != nil {
+		t.Fatal(err)
+	} else if !populated {
+		t.Error("expected a populated cgroup")
+	}
+}
+
+func TestStateDir(t *testing.T) {
+	cmd := Command(ResourceLimits{}, "sh", "-c", "echo $$; exit 3")
+	cmd.StateDir = t.TempDir()
 
 	out, err := cmd.Output()
+	if exitErr, ok := err.(*ExitError); !ok || exitErr.WaitStatus.ExitStatus() != 3 {
+		t.Fatalf("expected 'exit status 3', got '%v'", err)
+	}
+
+	// The command is run by its monitor
+	if pid := strings.TrimSpace(string(out)); pid != strconv.Itoa(cmd.Pid()) {
+		t.Errorf("expected '%d', got '%s'", cmd.Pid(), pid)
+	} else if cmd.Pid() == cmd.MonitorPID() {
+		t.Error("expected the command and its monitor to differ")
+	}
+
+	cmd = Command(ResourceLimits{}, "sleep", "0.3")
+	cmd.StateDir = t.TempDir()
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+
+	if populated, err := cmd.Cgroup().Populated(); err != nil {
+		t.Fatal(err)
+	} else if !populated {
+		t.Error("expected the command to be running")
+	}
+
+	// The monitor does not count against the limits of the command
+	if pids, err := cmd.Cgroup().Procs(); err != nil {
+		t.Fatal(err)
+	} else if len(pids) != 1 || pids[0] != cmd.Pid() {
+		t.Errorf("expected only '%d' in the cgroup, got '%v'", cmd.Pid(), pids)
+	}
+
+	rec, err := Recover(cmd.StateDir, cmd.CgroupName, false, cmd.MonitorPID())
 	if err != nil {
 		t.Fatal(err)
 	}
-	out = bytes.TrimSpace(out)
 
-	if !bytes.Equal(out, []byte(helloString)) {
-		t.Errorf("expected '%s', got '%s'", helloString, string(out))
+	if err := rec.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	if ws, ok := rec.WaitStatus(); !ok || !ws.Exited() || ws.ExitStatus() != 0 {
+		t.Errorf("expected 'exit status 0', got '%v'", ws)
+	}
+
+	// A process that started at another time than the monitor reused its PID,
+	// so the recovered command is not waited for
+	cmd = Command(ResourceLimits{}, "sleep", "5")
+	cmd.StateDir = t.TempDir()
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+
+	if err := os.WriteFile(path.Join(cmd.StateDir, startFile), []byte("1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec, err = Recover(cmd.StateDir, cmd.CgroupName, false, cmd.MonitorPID()); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := rec.Wait(); err == nil {
+		t.Error("expected the command to be reported as killed")
+	} else if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the command not to be waited for, took %s", elapsed)
+	}
+
+	cmd = Command(ResourceLimits{}, "sh")
+	cmd.StateDir, cmd.TTY = t.TempDir(), true
+	if err := cmd.Start(); err != ErrStateDirWithTTY {
+		t.Errorf("expected '%s', got '%v'", ErrStateDirWithTTY, err)
 	}
 }
@@ -0,0 +1,100 @@
+package resourcecontrol
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// cgroupMountPoint is where cgroup2 is mounted if it is missing, unless
+	// there is already a cgroup v1 hierarchy there
+	cgroupMountPoint = "/sys/fs/cgroup"
+
+	// hybridCgroupMountPoint is where cgroup2 is mounted alongside an existing
+	// cgroup v1 hierarchy
+	hybridCgroupMountPoint = "/sys/fs/cgroup/unified"
+)
+
+// ErrMissingControllers is returned by Setup when some of the controllers
+// could not be enabled, the limits depending on them will fail to apply
+var ErrMissingControllers = errors.New("some cgroup controllers could not be enabled")
+
+// supportedControllers lists every controller used to enforce the limits
+var supportedControllers = []string{"cpu", "cpuset", "io", "memory", "pids"}
+
+// mountCgroup mounts the cgroup2 filesystem
+func mountCgroup() error {
+	target := cgroupMountPoint
+
+	if v1, err := findMountPoint("cgroup"); err != nil {
+		return err
+	} else if v1 != "" {
+		target = hybridCgroupMountPoint
+	}
+
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+
+	return unix.Mount("cgroup2", target, "cgroup2", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, "")
+}
+
+// readControllers returns the controllers listed in the given cgroup file
+func readControllers(cgroupPath, file string) (map[string]bool, error) {
+	out, err := os.ReadFile(path.Join(cgroupPath, file))
+	if err != nil {
+		return nil, err
+	}
+
+	ctrls := make(map[string]bool)
+	for _, c := range strings.Fields(string(out)) {
+		ctrls[c] = true
+	}
+
+	return ctrls, nil
+}
+
+// Setup prepares the cgroup hierarchy used by the commands: cgroup2 is mounted
+// if needed, the controlSubtree cgroup is created and every supported
+// controller available in the hierarchy is delegated to it and to its leaf
+// cgroups. An error wrapping ErrMissingControllers is returned if any of the
+// supported controllers could not be enabled.
+func Setup() error {
+	rootPath, err := getCgroupRootPath()
+	if err != nil {
+		return err
+	}
+
+	subtreePath := path.Join(rootPath, controlSubtree)
+	if err := os.Mkdir(subtreePath, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+
+	available, err := readControllers(rootPath, "cgroup.controllers")
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, c := range supportedControllers {
+		// Controllers are enabled one by one, so a failing one does not
+		// prevent the others from being enabled
+		if !available[c] ||
+			enableControllers(rootPath, []string{c}) != nil ||
+			enableControllers(subtreePath, []string{c}) != nil {
+
+			missing = append(missing, c)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrMissingControllers, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
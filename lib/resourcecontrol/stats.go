@@ -0,0 +1,189 @@
+package resourcecontrol
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrCgroupRemoved = errors.New("cgroup was removed")
+
+// Stats holds the resource usage of a cgroup at a given time, the values of
+// the controllers that are not enabled in it are left empty
+type Stats struct {
+	Time   time.Time
+	CPU    CPUStats
+	Memory MemoryStats
+	IO     []IOStats
+
+	// Pids is the number of processes in the cgroup, from pids.current
+	Pids int64
+}
+
+// CPUStats holds the counters of cpu.stat, the throttling ones are only set if
+// the cpu controller is enabled
+type CPUStats struct {
+	UsageUsec        int64
+	UserUsec         int64
+	SystemUsec       int64
+	Periods          int64
+	ThrottledPeriods int64
+	ThrottledUsec    int64
+}
+
+// MemoryStats holds the memory usage in bytes, from memory.current and
+// memory.peak, along with the breakdown of memory.stat, such as "anon" or
+// "file"
+type MemoryStats struct {
+	Current int64
+	Peak    int64
+	Stat    map[string]int64
+}
+
+// IOStats holds the counters of io.stat for a single block device, identified
+// by its "major:minor" numbers
+type IOStats struct {
+	Device       string
+	ReadBytes    int64
+	WriteBytes   int64
+	ReadOps      int64
+	WriteOps     int64
+	DiscardBytes int64
+	DiscardOps   int64
+}
+
+// readCounters parses a flat keyed file of the cgroup holding integer values,
+// such as cpu.stat
+func (c *Cgroup) readCounters(file string) (map[string]int64, error) {
+	kvs, err := c.readKeyed(file)
+	if err != nil {
+		return nil, err
+	}
+
+	counters := make(map[string]int64, len(kvs))
+	for k, v := range kvs {
+		if counters[k], err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	return counters, nil
+}
+
+// readInt parses a single value file of the cgroup, such as memory.current
+func (c *Cgroup) readInt(file string) (int64, error) {
+	out, err := os.ReadFile(path.Join(c.path, file))
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(string(bytes.TrimSpace(out)), 10, 64)
+}
+
+// readIOStats parses io.stat, made of "major:minor key=value..." lines
+func (c *Cgroup) readIOStats() ([]IOStats, error) {
+	out, err := os.ReadFile(path.Join(c.path, "io.stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []IOStats
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		fs := strings.Fields(sc.Text())
+		if len(fs) == 0 {
+			continue
+		}
+
+		st := IOStats{Device: fs[0]}
+		for _, f := range fs[1:] {
+			kv := strings.SplitN(f, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+
+			v, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+
+			switch kv[0] {
+			case "rbytes":
+				st.ReadBytes = v
+			case "wbytes":
+				st.WriteBytes = v
+			case "rios":
+				st.ReadOps = v
+			case "wios":
+				st.WriteOps = v
+			case "dbytes":
+				st.DiscardBytes = v
+			case "dios":
+				st.DiscardOps = v
+			}
+		}
+		stats = append(stats, st)
+	}
+
+	return stats, sc.Err()
+}
+
+// statsError converts the errors caused by the removal of the cgroup while
+// reading its statistics into ErrCgroupRemoved
+func statsError(err error) error {
+	if isRemoved(err) {
+		return ErrCgroupRemoved
+	}
+
+	return err
+}
+
+// Stats returns the current resource usage of the cgroup, or ErrCgroupRemoved
+// if the cgroup no longer exists
+func (c *Cgroup) Stats() (Stats, error) {
+	st := Stats{Time: time.Now()}
+
+	// cpu.stat is always present, so its absence means that the cgroup is gone
+	cpu, err := c.readCounters("cpu.stat")
+	if err != nil {
+		return Stats{}, statsError(err)
+	}
+
+	st.CPU = CPUStats{
+		UsageUsec:        cpu["usage_usec"],
+		UserUsec:         cpu["user_usec"],
+		SystemUsec:       cpu["system_usec"],
+		Periods:          cpu["nr_periods"],
+		ThrottledPeriods: cpu["nr_throttled"],
+		ThrottledUsec:    cpu["throttled_usec"],
+	}
+
+	// The files of the controllers that are not enabled are missing
+	for _, f := range []struct {
+		file string
+		v    *int64
+	}{
+		{"memory.current", &st.Memory.Current},
+		{"memory.peak", &st.Memory.Peak},
+		{"pids.current", &st.Pids},
+	} {
+		if *f.v, err = c.readInt(f.file); err != nil && !os.IsNotExist(err) {
+			return Stats{}, statsError(err)
+		}
+	}
+
+	if st.Memory.Stat, err = c.readCounters("memory.stat"); err != nil && !os.IsNotExist(err) {
+		return Stats{}, statsError(err)
+	}
+
+	if st.IO, err = c.readIOStats(); err != nil && !os.IsNotExist(err) {
+		return Stats{}, statsError(err)
+	}
+
+	return st, nil
+}
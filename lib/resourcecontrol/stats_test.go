@@ -0,0 +1,72 @@
+package resourcecontrol
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	c := &Cgroup{path: t.TempDir()}
+
+	for file, contents := range map[string]string{
+		"cpu.stat":       "usage_usec 3000\nuser_usec 2000\nsystem_usec 1000\nnr_periods 10\nnr_throttled 2\nthrottled_usec 500\n",
+		"memory.current": "4096\n",
+		"memory.stat":    "anon 1024\nfile 2048\n",
+		"io.stat":        "8:0 rbytes=100 wbytes=200 rios=1 wios=2 dbytes=0 dios=0\n",
+	} {
+		if err := os.WriteFile(path.Join(c.path, file), []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st, err := c.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The files of missing controllers, such as pids.current, are skipped
+	expected := Stats{
+		Time:   st.Time,
+		CPU:    CPUStats{UsageUsec: 3000, UserUsec: 2000, SystemUsec: 1000, Periods: 10, ThrottledPeriods: 2, ThrottledUsec: 500},
+		Memory: MemoryStats{Current: 4096, Stat: map[string]int64{"anon": 1024, "file": 2048}},
+		IO:     []IOStats{{Device: "8:0", ReadBytes: 100, WriteBytes: 200, ReadOps: 1, WriteOps: 2}},
+	}
+
+	if !reflect.DeepEqual(st, expected) {
+		t.Errorf("expected '%+v', got '%+v'", expected, st)
+	}
+
+	// A removed cgroup has no cpu.stat
+	c.path = path.Join(c.path, "removed")
+	if _, err := c.Stats(); err != ErrCgroupRemoved {
+		t.Errorf("expected '%s', got '%v'", ErrCgroupRemoved, err)
+	}
+}
+
+func TestPressure(t *testing.T) {
+	c := &Cgroup{path: t.TempDir()}
+
+	contents := "some avg10=1.50 avg60=2.25 avg300=0.00 total=1234\nfull avg10=0.50 avg60=0.00 avg300=0.00 total=56\n"
+	if err := os.WriteFile(path.Join(c.path, "memory.pressure"), []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := c.Pressure("memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Pressure{
+		Some: PressureStats{Avg10: 1.5, Avg60: 2.25, TotalUsec: 1234},
+		Full: PressureStats{Avg10: 0.5, TotalUsec: 56},
+	}
+	if p != expected {
+		t.Errorf("expected '%+v', got '%+v'", expected, p)
+	}
+
+	if _, err := c.Pressure("io"); !os.IsNotExist(err) {
+		t.Errorf("expected a missing file, got '%v'", err)
+	}
+}
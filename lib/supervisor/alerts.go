@@ -0,0 +1,279 @@
+package supervisor
+
+import (
+	"os"
+	"time"
+
+	"github.com/andres-teleport/overseer/lib/resourcecontrol"
+)
+
+// maxAlerts is the number of alerts kept in the status of a job, the oldest
+// cleared ones are dropped first
+const maxAlerts = 64
+
+// MonitorInterval is how often the cgroups of the running jobs are sampled to
+// raise and clear their alerts
+var MonitorInterval = time.Second
+
+type AlertKind int
+
+const (
+	AlertCPUPressure AlertKind = iota
+	AlertMemoryPressure
+	AlertIOPressure
+	AlertCPUThrottled
+	AlertMemoryHigh
+	AlertMemoryMax
+	AlertOOM
+)
+
+// alertKinds lists the kinds of alerts in the order they are evaluated
+var alertKinds = []AlertKind{
+	AlertCPUPressure,
+	AlertMemoryPressure,
+	AlertIOPressure,
+	AlertCPUThrottled,
+	AlertMemoryHigh,
+	AlertMemoryMax,
+	AlertOOM,
+}
+
+var alertKindNames = map[AlertKind]string{
+	AlertCPUPressure:    "CPU pressure",
+	AlertMemoryPressure: "memory pressure",
+	AlertIOPressure:     "IO pressure",
+	AlertCPUThrottled:   "CPU throttled",
+	AlertMemoryHigh:     "memory high",
+	AlertMemoryMax:      "memory max",
+	AlertOOM:            "OOM",
+}
+
+func (k AlertKind) String() string {
+	return alertKindNames[k]
+}
+
+// AlertThresholds are the percentages above which the alerts of a job are
+// raised, a zero threshold disables its alert. The pressure thresholds are
+// compared with the share of time some of the processes of the job were
+// stalled waiting for the resource over the last 10 seconds, and CPUThrottled
+// with the share of CPU periods in which the job was throttled since the
+// previous sample. The memory.events alerts (high, max and OOM) are raised
+// whenever their counters increase.
+type AlertThresholds struct {
+	CPUPressure    float64
+	MemoryPressure float64
+	IOPressure     float64
+	CPUThrottled   float64
+}
+
+// DefaultAlertThresholds are the thresholds used by NewSupervisor
+var DefaultAlertThresholds = AlertThresholds{
+	CPUPressure:    50,
+	MemoryPressure: 20,
+	IOPressure:     50,
+	CPUThrottled:   50,
+}
+
+// Alert records a period of time during which a job crossed the threshold of
+// an alert. Value is the one that crossed Threshold, a percentage or, for the
+// memory.events alerts, the number of new events. End is zero while the alert
+// is active.
+type Alert struct {
+	Kind      AlertKind
+	Value     float64
+	Threshold float64
+	Start     time.Time
+	End       time.Time
+}
+
+// thresholds returns the enabled thresholds by kind of alert
+func (th AlertThresholds) thresholds() map[AlertKind]float64 {
+	ths := map[AlertKind]float64{
+		AlertMemoryHigh: 0,
+		AlertMemoryMax:  0,
+		AlertOOM:        0,
+	}
+
+	for kind, v := range map[AlertKind]float64{
+		AlertCPUPressure:    th.CPUPressure,
+		AlertMemoryPressure: th.MemoryPressure,
+		AlertIOPressure:     th.IOPressure,
+		AlertCPUThrottled:   th.CPUThrottled,
+	} {
+		if v > 0 {
+			ths[kind] = v
+		}
+	}
+
+	return ths
+}
+
+// sample holds the readings of the cgroup of a job the alerts are based on
+type sample struct {
+	pressure     map[AlertKind]float64
+	cpu          resourcecontrol.CPUStats
+	memoryEvents map[string]int64
+}
+
+// readSample reads the files of the cgroup the alerts are based on, the
+// pressure is left out on kernels without PSI
+func readSample(cgroup *resourcecontrol.Cgroup) (sample, error) {
+	sm := sample{pressure: make(map[AlertKind]float64)}
+
+	for kind, resource := range map[AlertKind]string{
+		AlertCPUPressure:    "cpu",
+		AlertMemoryPressure: "memory",
+		AlertIOPressure:     "io",
+	} {
+		p, err := cgroup.Pressure(resource)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return sample{}, err
+		}
+		sm.pressure[kind] = p.Some.Avg10
+	}
+
+	st, err := cgroup.Stats()
+	if err != nil {
+		return sample{}, err
+	}
+	sm.cpu = st.CPU
+
+	if sm.memoryEvents, err = cgroup.MemoryEvents(); err != nil {
+		return sample{}, err
+	}
+
+	return sm, nil
+}
+
+// alertValues returns the value of each kind of alert given the current and
+// previous samples, the counters are compared between both
+func alertValues(prev, cur sample) map[AlertKind]float64 {
+	values := map[AlertKind]float64{
+		AlertMemoryHigh: float64(cur.memoryEvents["high"] - prev.memoryEvents["high"]),
+		AlertMemoryMax:  float64(cur.memoryEvents["max"] - prev.memoryEvents["max"]),
+		AlertOOM:        float64(cur.memoryEvents["oom"] - prev.memoryEvents["oom"]),
+	}
+
+	for kind, v := range cur.pressure {
+		values[kind] = v
+	}
+
+	if periods := cur.cpu.Periods - prev.cpu.Periods; periods > 0 {
+		values[AlertCPUThrottled] = 100 * float64(cur.cpu.ThrottledPeriods-prev.cpu.ThrottledPeriods) / float64(periods)
+	}
+
+	return values
+}
+
+// activeAlert returns the index of the active alert of the given kind in the
+// status of the job, or -1 if there is none
+func (j *Job) activeAlert(kind AlertKind) int {
+	for i, a := range j.status.Alerts {
+		if a.Kind == kind && a.End.IsZero() {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// setAlerts replaces the alerts of the job, dropping the oldest cleared ones
+// beyond maxAlerts. The previous slice is never modified, since it might be
+// shared with the statuses returned before.
+func (j *Job) setAlerts(alerts []Alert) {
+	for i := 0; len(alerts) > maxAlerts && i < len(alerts); {
+		if alerts[i].End.IsZero() {
+			i++
+		} else {
+			alerts = append(alerts[:i], alerts[i+1:]...)
+		}
+	}
+
+	j.status.Alerts = alerts
+}
+
+// updateAlerts raises the alerts whose values are above their thresholds and
+// clears the active ones that are no longer above them, publishing an event
+// for each change. It must be called with the lock held.
+func (s *Supervisor) updateAlerts(id string, j *Job, values, thresholds map[AlertKind]float64, now time.Time) {
+	for _, kind := range alertKinds {
+		threshold, ok := thresholds[kind]
+		if !ok {
+			continue
+		}
+
+		value, i := values[kind], j.activeAlert(kind)
+
+		switch {
+		case value > threshold && i < 0:
+			a := Alert{Kind: kind, Value: value, Threshold: threshold, Start: now}
+			j.setAlerts(append(append([]Alert(nil), j.status.Alerts...), a))
+			s.publishAlert(EventAlert, id, j, a)
+		case value <= threshold && i >= 0:
+			alerts := append([]Alert(nil), j.status.Alerts...)
+			alerts[i].End = now
+			j.setAlerts(alerts)
+			s.publishAlert(EventAlertCleared, id, j, alerts[i])
+		}
+	}
+}
+
+// endAlerts ends the active alerts of a finished job at the given time without
+// publishing any events, it must be called with the lock held
+func (j *Job) endAlerts(end time.Time) {
+	alerts := append([]Alert(nil), j.status.Alerts...)
+	for i := range alerts {
+		if alerts[i].End.IsZero() {
+			alerts[i].End = end
+		}
+	}
+
+	j.setAlerts(alerts)
+}
+
+// monitor samples the cgroup of the job every MonitorInterval, updating its
+// alerts, until the job has finished
+func (s *Supervisor) monitor(id string, j *Job, thresholds AlertThresholds) {
+	ticker := time.NewTicker(MonitorInterval)
+	defer ticker.Stop()
+
+	cgroup := j.cmd.Cgroup()
+	s.watchAlerts(id, j, thresholds.thresholds(), ticker.C, func() (sample, error) {
+		return readSample(cgroup)
+	})
+}
+
+// watchAlerts updates the alerts of the job with a sample read on every tick,
+// until the job has finished. The counters are compared with a first sample
+// read right away, as the cgroup of a recovered job was counting before.
+func (s *Supervisor) watchAlerts(id string, j *Job, ths map[AlertKind]float64, tick <-chan time.Time, read func() (sample, error)) {
+	// Otherwise the next sample read is the baseline
+	prev, err := read()
+	baseline := err == nil
+
+	for {
+		select {
+		case <-j.done:
+			return
+		case <-tick:
+		}
+
+		// The cgroup is removed once the job has finished
+		cur, err := read()
+		if err != nil {
+			continue
+		}
+
+		if baseline {
+			s.mu.Lock()
+			if !j.finished() {
+				s.updateAlerts(id, j, alertValues(prev, cur), ths, time.Now())
+			}
+			s.mu.Unlock()
+		}
+
+		prev, baseline = cur, true
+	}
+}
@@ -0,0 +1,149 @@
+package supervisor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andres-teleport/overseer/lib/resourcecontrol"
+)
+
+func TestAlertValues(t *testing.T) {
+	prev := sample{
+		cpu:          resourcecontrol.CPUStats{Periods: 10, ThrottledPeriods: 5},
+		memoryEvents: map[string]int64{"high": 3},
+	}
+	cur := sample{
+		pressure:     map[AlertKind]float64{AlertMemoryPressure: 12.5},
+		cpu:          resourcecontrol.CPUStats{Periods: 20, ThrottledPeriods: 13},
+		memoryEvents: map[string]int64{"high": 5, "max": 1},
+	}
+
+	values := alertValues(prev, cur)
+
+	for kind, expected := range map[AlertKind]float64{
+		AlertMemoryPressure: 12.5,
+		AlertCPUThrottled:   80,
+		AlertMemoryHigh:     2,
+		AlertMemoryMax:      1,
+		AlertOOM:            0,
+	} {
+		if values[kind] != expected {
+			t.Errorf("%s: expected %v, got %v", kind, expected, values[kind])
+		}
+	}
+}
+
+func TestUpdateAlerts(t *testing.T) {
+	sup := NewSupervisor()
+	j := &Job{}
+	ths := AlertThresholds{CPUThrottled: 50}.thresholds()
+
+	sub := sup.Subscribe()
+	defer sub.Close()
+
+	start := time.Now()
+	for i, values := range []map[AlertKind]float64{
+		{AlertCPUThrottled: 80, AlertCPUPressure: 90}, // CPU pressure is disabled
+		{AlertCPUThrottled: 90, AlertMemoryMax: 1},
+		{AlertCPUThrottled: 10},
+	} {
+		sup.updateAlerts("job", j, values, ths, start.Add(time.Duration(i)*time.Second))
+	}
+
+	// Every change is published once
+	for _, expected := range []struct {
+		t    EventType
+		kind AlertKind
+	}{
+		{EventAlert, AlertCPUThrottled},
+		{EventAlert, AlertMemoryMax},
+		{EventAlertCleared, AlertCPUThrottled},
+		{EventAlertCleared, AlertMemoryMax},
+	} {
+		ev := <-sub.C
+		if ev.Type != expected.t || ev.Alert.Kind != expected.kind {
+			t.Errorf("expected '%s %s', got '%s %s'", expected.t, expected.kind, ev.Type, ev.Alert.Kind)
+		}
+	}
+
+	expected := []Alert{
+		{Kind: AlertCPUThrottled, Value: 80, Threshold: 50, Start: start, End: start.Add(2 * time.Second)},
+		{Kind: AlertMemoryMax, Value: 1, Start: start.Add(time.Second), End: start.Add(2 * time.Second)},
+	}
+
+	if len(j.status.Alerts) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, j.status.Alerts)
+	}
+
+	for i := range expected {
+		if j.status.Alerts[i] != expected[i] {
+			t.Errorf("expected '%+v', got '%+v'", expected[i], j.status.Alerts[i])
+		}
+	}
+}
+
+func TestWatchAlertsBaseline(t *testing.T) {
+	sup := NewSupervisor()
+	j := &Job{done: make(chan struct{})}
+	ths := AlertThresholds{CPUThrottled: 50}.thresholds()
+
+	// The cgroup of a recovered job was throttled and hit its memory limits
+	// before the supervisor started
+	before := sample{
+		cpu:          resourcecontrol.CPUStats{Periods: 100, ThrottledPeriods: 90},
+		memoryEvents: map[string]int64{"high": 5, "max": 2, "oom": 1},
+	}
+	samples := make(chan sample, 3)
+	samples <- before
+	samples <- before
+	samples <- sample{
+		cpu:          resourcecontrol.CPUStats{Periods: 110, ThrottledPeriods: 90},
+		memoryEvents: map[string]int64{"high": 6, "max": 2, "oom": 1},
+	}
+
+	tick, finished := make(chan time.Time), make(chan struct{})
+	go func() {
+		sup.watchAlerts("job", j, ths, tick, func() (sample, error) {
+			return <-samples, nil
+		})
+		close(finished)
+	}()
+
+	tick <- time.Now()
+	tick <- time.Now()
+	close(j.done)
+	<-finished
+
+	// Only the events counted since the first sample raise alerts
+	if len(j.status.Alerts) != 1 {
+		t.Fatalf("expected a single alert, got %+v", j.status.Alerts)
+	}
+
+	if a := j.status.Alerts[0]; a.Kind != AlertMemoryHigh || a.Value != 1 {
+		t.Errorf("expected '%s' with 1, got '%s' with %v", AlertMemoryHigh, a.Kind, a.Value)
+	}
+}
+
+func TestSetAlerts(t *testing.T) {
+	var j Job
+	now := time.Now()
+
+	// The active alert is kept even if it is the oldest one
+	alerts := []Alert{{Kind: AlertOOM}}
+	for i := 0; i < maxAlerts; i++ {
+		alerts = append(alerts, Alert{Kind: AlertMemoryHigh, Value: float64(i), End: now})
+	}
+	j.setAlerts(alerts)
+
+	if len(j.status.Alerts) != maxAlerts {
+		t.Fatalf("expected %d alerts, got %d", maxAlerts, len(j.status.Alerts))
+	}
+
+	if a := j.status.Alerts[0]; a.Kind != AlertOOM {
+		t.Errorf("expected '%s', got '%s'", AlertOOM, a.Kind)
+	}
+
+	if a := j.status.Alerts[1]; a.Value != 1 {
+		t.Errorf("expected 1, got %v", a.Value)
+	}
+}
@@ -0,0 +1,153 @@
+package supervisor
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// subscriptionBuffer is the number of events a subscriber can fall behind
+// before it is dropped
+const subscriptionBuffer = 256
+
+var ErrEventsDropped = errors.New("events were dropped, the subscriber fell behind")
+
+type EventType int
+
+const (
+	EventStarted EventType = iota
+	EventPaused
+	EventResumed
+	EventExited
+	EventStopped
+	EventOOMKilled
+	EventAlert
+	EventAlertCleared
+)
+
+var eventTypeNames = map[EventType]string{
+	EventStarted:      "started",
+	EventPaused:       "paused",
+	EventResumed:      "resumed",
+	EventExited:       "exited",
+	EventStopped:      "stopped",
+	EventOOMKilled:    "OOM killed",
+	EventAlert:        "alert",
+	EventAlertCleared: "alert cleared",
+}
+
+func (t EventType) String() string {
+	return eventTypeNames[t]
+}
+
+// Event is a change in the lifecycle of a job, Status is the status of the job
+// right after it. Alert is the alert raised or cleared by the alert events.
+type Event struct {
+	Type   EventType
+	JobID  string
+	Time   time.Time
+	Status Status
+	Alert  Alert
+}
+
+// Subscription receives the events published by the supervisor after it was
+// created. C is closed when the subscription is closed or when the subscriber
+// falls behind, in which case Err returns ErrEventsDropped.
+type Subscription struct {
+	C <-chan Event
+
+	c   chan Event
+	bus *eventBus
+	err error
+}
+
+// Close stops the delivery of events and closes C
+func (sub *Subscription) Close() {
+	sub.bus.mu.Lock()
+	defer sub.bus.mu.Unlock()
+
+	sub.bus.remove(sub)
+}
+
+// Err returns ErrEventsDropped if C was closed because the subscriber fell
+// behind, or nil otherwise
+func (sub *Subscription) Err() error {
+	sub.bus.mu.Lock()
+	defer sub.bus.mu.Unlock()
+
+	return sub.err
+}
+
+// eventBus fans out the events to the subscribers without ever blocking the
+// publisher
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+func (b *eventBus) subscribe() *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subs == nil {
+		b.subs = make(map[*Subscription]struct{})
+	}
+
+	c := make(chan Event, subscriptionBuffer)
+	sub := &Subscription{C: c, c: c, bus: b}
+	b.subs[sub] = struct{}{}
+
+	return sub
+}
+
+// remove must be called with the lock held
+func (b *eventBus) remove(sub *Subscription) {
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.c)
+	}
+}
+
+func (b *eventBus) publish(ev Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		select {
+		case sub.c <- ev:
+		default:
+			sub.err = ErrEventsDropped
+			b.remove(sub)
+		}
+	}
+}
+
+// Subscribe returns a subscription to the lifecycle events of all the jobs,
+// it must be closed once it is no longer needed
+func (s *Supervisor) Subscribe() *Subscription {
+	return s.events.subscribe()
+}
+
+// publish sends an event about the given job to the subscribers, it must be
+// called with the lock held so that the events follow the order of the
+// changes
+func (s *Supervisor) publish(t EventType, id string, j *Job) {
+	s.events.publish(Event{
+		Type:   t,
+		JobID:  id,
+		Time:   time.Now(),
+		Status: j.status,
+	})
+}
+
+// publishAlert sends an event about an alert of the given job to the
+// subscribers, it must be called with the lock held
+func (s *Supervisor) publishAlert(t EventType, id string, j *Job, a Alert) {
+	s.events.publish(Event{
+		Type:   t,
+		JobID:  id,
+		Time:   time.Now(),
+		Status: j.status,
+		Alert:  a,
+	})
+}
@@ -0,0 +1,39 @@
+package supervisor
+
+import "testing"
+
+func TestEventBus(t *testing.T) {
+	var bus eventBus
+
+	fast, slow := bus.subscribe(), bus.subscribe()
+
+	for i := 0; i <= subscriptionBuffer; i++ {
+		bus.publish(Event{Type: EventStarted})
+		<-fast.C
+	}
+
+	// The slow subscriber fell behind, so its channel is drained and closed
+	n := 0
+	for range slow.C {
+		n++
+	}
+
+	if n != subscriptionBuffer {
+		t.Errorf("expected %d events, got %d", subscriptionBuffer, n)
+	}
+
+	if err := slow.Err(); err != ErrEventsDropped {
+		t.Errorf("expected '%s', got '%v'", ErrEventsDropped, err)
+	}
+
+	fast.Close()
+	fast.Close()
+
+	if _, ok := <-fast.C; ok {
+		t.Error("expected a closed channel")
+	}
+
+	if err := fast.Err(); err != nil {
+		t.Errorf("expected no error, got '%s'", err)
+	}
+}
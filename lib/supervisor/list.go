@@ -0,0 +1,140 @@
+package supervisor
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// JobInfo is the status of a job along with its ID
+type JobInfo struct {
+	ID string
+	Status
+}
+
+// JobFilter selects the jobs returned by ListJobs, empty fields match every
+// job
+type JobFilter struct {
+	// Owner restricts the jobs to the ones started by the given owner
+	Owner string
+
+	// Statuses restricts the jobs to the ones in any of the given statuses
+	Statuses []int
+
+	// Labels restricts the jobs to the ones having all the given labels
+	Labels map[string]string
+}
+
+func (f JobFilter) match(st Status) bool {
+	if f.Owner != "" && f.Owner != st.Owner {
+		return false
+	}
+
+	if len(f.Statuses) > 0 {
+		found := false
+		for _, s := range f.Statuses {
+			found = found || s == st.Status
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	for k, v := range f.Labels {
+		if lv, ok := st.Labels[k]; !ok || lv != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// listKey is the position of a job in the listing order, jobs are listed by
+// start time and then by ID
+type listKey struct {
+	startTime time.Time
+	id        string
+}
+
+func (k listKey) before(o listKey) bool {
+	if !k.startTime.Equal(o.startTime) {
+		return k.startTime.Before(o.startTime)
+	}
+
+	return k.id < o.id
+}
+
+// encodePageToken returns an opaque token pointing past the given position
+func encodePageToken(k listKey) string {
+	key := strconv.FormatInt(k.startTime.UnixNano(), 10) + "/" + k.id
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodePageToken(token string) (listKey, error) {
+	key, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return listKey{}, ErrInvalidPageToken
+	}
+
+	fs := strings.SplitN(string(key), "/", 2)
+	if len(fs) != 2 {
+		return listKey{}, ErrInvalidPageToken
+	}
+
+	nsec, err := strconv.ParseInt(fs[0], 10, 64)
+	if err != nil {
+		return listKey{}, ErrInvalidPageToken
+	}
+
+	return listKey{time.Unix(0, nsec), fs[1]}, nil
+}
+
+// ListJobs returns the jobs matching the filter ordered by start time. At most
+// pageSize jobs are returned, or all of them if it is not positive, along with
+// a token to pass to the next call in order to get the next page. The token is
+// empty when there are no more jobs left.
+func (s *Supervisor) ListJobs(filter JobFilter, pageToken string, pageSize int) ([]JobInfo, string, error) {
+	var after listKey
+
+	if pageToken != "" {
+		var err error
+		if after, err = decodePageToken(pageToken); err != nil {
+			return nil, "", err
+		}
+	}
+
+	var jobs []JobInfo
+
+	s.mu.Lock()
+	for id, j := range s.processes {
+		if !filter.match(j.status) {
+			continue
+		}
+
+		if pageToken != "" && !after.before(listKey{j.status.StartTime, id}) {
+			continue
+		}
+
+		jobs = append(jobs, JobInfo{ID: id, Status: j.status})
+	}
+	s.mu.Unlock()
+
+	sort.Slice(jobs, func(a, b int) bool {
+		return listKey{jobs[a].StartTime, jobs[a].ID}.before(listKey{jobs[b].StartTime, jobs[b].ID})
+	})
+
+	if pageSize <= 0 || len(jobs) <= pageSize {
+		return jobs, "", nil
+	}
+
+	jobs = jobs[:pageSize]
+	last := jobs[len(jobs)-1]
+
+	return jobs, encodePageToken(listKey{last.StartTime, last.ID}), nil
+}
@@ -0,0 +1,96 @@
+package supervisor
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newListTestSupervisor(n int) *Supervisor {
+	sup := NewSupervisor()
+	start := time.Now()
+
+	for i := 0; i < n; i++ {
+		st := StatusDone
+		if i%2 == 0 {
+			st = StatusStarted
+		}
+
+		sup.processes["job-"+strconv.Itoa(i)] = &Job{
+			status: Status{
+				Status:    st,
+				StartTime: start.Add(time.Duration(i) * time.Second),
+				Labels:    map[string]string{"even": strconv.FormatBool(i%2 == 0)},
+				Owner:     "user-" + strconv.Itoa(i%3),
+			},
+		}
+	}
+
+	return sup
+}
+
+func TestListJobsPagination(t *testing.T) {
+	sup := newListTestSupervisor(5)
+
+	var ids []string
+	token := ""
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("too many pages")
+		}
+
+		jobs, next, err := sup.ListJobs(JobFilter{}, token, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, j := range jobs {
+			ids = append(ids, j.ID)
+		}
+
+		if token = next; token == "" {
+			break
+		}
+	}
+
+	if len(ids) != 5 {
+		t.Fatalf("expected 5 jobs, got %d", len(ids))
+	}
+
+	for i, id := range ids {
+		if expected := "job-" + strconv.Itoa(i); id != expected {
+			t.Errorf("expected '%s', got '%s'", expected, id)
+		}
+	}
+
+	if _, _, err := sup.ListJobs(JobFilter{}, "invalid token", 2); err != ErrInvalidPageToken {
+		t.Errorf("expected '%s', got '%v'", ErrInvalidPageToken, err)
+	}
+}
+
+func TestListJobsFilter(t *testing.T) {
+	sup := newListTestSupervisor(5)
+
+	for _, tc := range []struct {
+		filter   JobFilter
+		expected int
+	}{
+		{JobFilter{}, 5},
+		{JobFilter{Statuses: []int{StatusStarted}}, 3},
+		{JobFilter{Statuses: []int{StatusStarted, StatusDone}}, 5},
+		{JobFilter{Labels: map[string]string{"even": "false"}}, 2},
+		{JobFilter{Labels: map[string]string{"missing": "label"}}, 0},
+		{JobFilter{Owner: "user-0"}, 2},
+		{JobFilter{Owner: "user-3"}, 0},
+		{JobFilter{Owner: "user-1", Statuses: []int{StatusStarted}}, 1},
+	} {
+		jobs, _, err := sup.ListJobs(tc.filter, "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(jobs) != tc.expected {
+			t.Errorf("%+v: expected %d jobs, got %d", tc.filter, tc.expected, len(jobs))
+		}
+	}
+}
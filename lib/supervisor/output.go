@@ -0,0 +1,198 @@
+package supervisor
+
+import (
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/andres-teleport/overseer/lib/multipipe"
+)
+
+const (
+	// stdoutLog and stderrLog are the files the output of a job is written
+	// to in its directory
+	stdoutLog = "stdout"
+	stderrLog = "stderr"
+
+	// logPollInterval is how often the output files of a job are checked for
+	// new contents
+	logPollInterval = 50 * time.Millisecond
+
+	// outputTailSize is the amount of the last output of a job kept in memory
+	// when it is stored in files, the rest is read from them
+	outputTailSize = 64 << 10
+
+	// limitRetryInterval is how often stopping a job whose output went past
+	// its limit is retried
+	limitRetryInterval = 100 * time.Millisecond
+)
+
+// outputLogs copies the files the output of a job is written to into its pipes
+// as they grow, since the processes of the job write to them directly
+type outputLogs struct {
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// openLogs opens the output files in the given job directory for writing,
+// creating them if needed
+func openLogs(dir string) (stdout, stderr *os.File, err error) {
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return nil, nil, err
+	}
+
+	if stdout, err = os.OpenFile(path.Join(dir, stdoutLog), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600); err != nil {
+		return nil, nil, err
+	}
+
+	if stderr, err = os.OpenFile(path.Join(dir, stderrLog), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600); err != nil {
+		stdout.Close()
+		return nil, nil, err
+	}
+
+	return stdout, stderr, nil
+}
+
+// outputDir returns the directory where the output of the job with the given ID
+// is stored, it is empty if the output is only kept in memory
+func (s *Supervisor) outputDir(id string) string {
+	switch {
+	case s.store != nil:
+		return s.store.jobDir(id)
+	case s.OutputDir != "":
+		return path.Join(s.OutputDir, id)
+	}
+
+	return ""
+}
+
+// createOutput returns the pipes of a job writing its output to them, stored in
+// new files in the given directory, which is created if needed, and retained as
+// given
+func createOutput(dir string, r multipipe.Retention) (stdout, stderr *multipipe.MultiPipe, err error) {
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return nil, nil, err
+	}
+
+	if stdout, err = multipipe.NewFileMultiPipe(path.Join(dir, stdoutLog), outputTailSize, r); err != nil {
+		return nil, nil, err
+	}
+
+	if stderr, err = multipipe.NewFileMultiPipe(path.Join(dir, stderrLog), outputTailSize, r); err != nil {
+		stdout.Close()
+		return nil, nil, err
+	}
+
+	return stdout, stderr, nil
+}
+
+// openOutput returns the pipes holding the contents of the output files in the
+// given job directory, which followLogs copies to them as they grow, retained as
+// given. A missing file is treated as an empty one.
+func openOutput(dir string, r multipipe.Retention) (stdout, stderr *multipipe.MultiPipe, err error) {
+	if stdout, err = openLog(dir, stdoutLog, r); err != nil {
+		return nil, nil, err
+	}
+
+	if stderr, err = openLog(dir, stderrLog, r); err != nil {
+		return nil, nil, err
+	}
+
+	return stdout, stderr, nil
+}
+
+// openLog returns a pipe holding the contents of the given output file of a job
+func openLog(dir, name string, r multipipe.Retention) (*multipipe.MultiPipe, error) {
+	mp, err := multipipe.OpenFileMultiPipe(path.Join(dir, name), outputTailSize, r)
+	if os.IsNotExist(err) {
+		return multipipe.NewMultiPipeWithRetention(r), nil
+	}
+
+	return mp, err
+}
+
+// followLogs copies the output files in the given job directory to the given
+// pipes from the end of their contents, a missing file is treated as an empty
+// one
+func followLogs(dir string, stdout, stderr *multipipe.MultiPipe) *outputLogs {
+	l := &outputLogs{stop: make(chan struct{})}
+
+	for name, mp := range map[string]*multipipe.MultiPipe{stdoutLog: stdout, stderrLog: stderr} {
+		f, err := os.Open(path.Join(dir, name))
+		if err != nil {
+			continue
+		}
+
+		if _, err := f.Seek(mp.Size(), io.SeekStart); err != nil {
+			f.Close()
+			continue
+		}
+
+		l.wg.Add(1)
+		go l.follow(f, mp)
+	}
+
+	return l
+}
+
+// follow copies the file to the pipe until the logs are closed and the end of
+// the file has been reached
+func (l *outputLogs) follow(f *os.File, mp *multipipe.MultiPipe) {
+	defer l.wg.Done()
+	defer f.Close()
+
+	for {
+		// Everything written before stopping is copied
+		stopped := false
+		select {
+		case <-l.stop:
+			stopped = true
+		default:
+		}
+
+		if _, err := io.Copy(mp, f); err != nil || stopped {
+			return
+		}
+
+		select {
+		case <-l.stop:
+		case <-time.After(logPollInterval):
+		}
+	}
+}
+
+// close waits for the rest of the files to be copied, it must be called once
+// the processes of the job have exited
+func (l *outputLogs) close() {
+	close(l.stop)
+	l.wg.Wait()
+}
+
+// limitOutput stops the job with the given ID once its output goes past the
+// limit of its retention, the job must be registered already
+func (s *Supervisor) limitOutput(id string, job *Job) {
+	select {
+	case <-job.stdout.Overflowed():
+	case <-job.stderr.Overflowed():
+	case <-job.done:
+		return
+	}
+
+	for {
+		// The job might have finished or been stopped meanwhile, otherwise
+		// stopping it is retried, as it fails while the job is busy or
+		// while a graceful stop that might fail is in progress
+		switch s.StopJob(id) {
+		case nil, ErrJobFinished, ErrUnknownJobID:
+			return
+		}
+
+		select {
+		case <-job.done:
+			return
+		case <-time.After(limitRetryInterval):
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package supervisor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/andres-teleport/overseer/lib/multipipe"
+	"github.com/andres-teleport/overseer/lib/resourcecontrol"
+)
+
+// journalFile is the name of the job journal in the state directory
+const journalFile = "jobs.journal"
+
+// record is a line of the job journal, the status of a job when it was started
+// or when it finished. The last record of a job holds its latest status.
+type record struct {
+	ID     string `json:"id"`
+	Status Status `json:"status"`
+
+	// MonitorPID is the PID of the monitor of a job run under one, needed to
+	// recover it
+	MonitorPID int `json:"monitorPid,omitempty"`
+
+	// Retention selects the output of the job that is kept
+	Retention multipipe.Retention `json:"retention"`
+}
+
+// store is an append-only journal of the jobs, one JSON record per line, kept
+// in a state directory along with a directory per job holding its output and
+// the files written by its monitor
+type store struct {
+	mu  sync.Mutex
+	f   *os.File
+	dir string
+}
+
+// readJournal returns the latest record of each job found in the journal at
+// the given path, ordered by start time. A last line without a newline, which
+// is left behind if the supervisor stops while writing it, is ignored.
+func readJournal(journalPath string) ([]record, error) {
+	f, err := os.Open(journalPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	latest := make(map[string]record)
+	rd := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := rd.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if line = bytes.TrimSpace(line); len(line) == 0 {
+			continue
+		}
+
+		var r record
+		if err := json.Unmarshal(line, &r); err != nil || r.ID == "" {
+			return nil, fmt.Errorf("%s:%d: invalid record", journalPath, n)
+		}
+		latest[r.ID] = r
+	}
+
+	records := make([]record, 0, len(latest))
+	for _, r := range latest {
+		records = append(records, r)
+	}
+
+	sort.Slice(records, func(a, b int) bool {
+		return listKey{records[a].Status.StartTime, records[a].ID}.before(listKey{records[b].Status.StartTime, records[b].ID})
+	})
+
+	return records, nil
+}
+
+// writeJournal atomically replaces the journal at the given path with one
+// holding only the given records
+func writeJournal(journalPath string, records []record) error {
+	tmpPath := journalPath + ".tmp"
+
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err = enc.Encode(r); err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, journalPath)
+}
+
+// openStore loads the journal of the given state directory, creating both if
+// needed, and returns the store appending to it along with the latest record of
+// each job. The journal is compacted to a single record per job.
+func openStore(stateDir string) (*store, []record, error) {
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return nil, nil, err
+	}
+
+	journalPath := path.Join(stateDir, journalFile)
+
+	records, err := readJournal(journalPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := writeJournal(journalPath, records); err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &store{f: f, dir: stateDir}, records, nil
+}
+
+// jobDir returns the directory of the job with the given ID
+func (st *store) jobDir(id string) string {
+	return path.Join(st.dir, "jobs", id)
+}
+
+// save appends the given record to the journal, it is written to disk before
+// returning
+func (st *store) save(r record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if _, err := st.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	return st.f.Sync()
+}
+
+// NewSupervisorWithState returns a Supervisor like NewSupervisor, with the
+// given alert thresholds, that records the jobs, their owners and their final
+// status in a journal kept in the given state directory, which is created if
+// needed. The jobs are run under a monitor and write their output to files in
+// the directory, so they keep running if the supervisor stops. The jobs
+// recorded by a previous supervisor are loaded along with their output, the
+// ones still running are recovered and can be controlled as before, except for
+// the jobs with a terminal, which are reported as stopped.
+func NewSupervisorWithState(stateDir string, thresholds AlertThresholds) (*Supervisor, error) {
+	st, records, err := openStore(stateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewSupervisor()
+	s.AlertThresholds = thresholds
+	s.store = st
+
+	recovered := make(map[string]*Job)
+	for _, r := range records {
+		j := &Job{status: r.Status, retention: r.Retention, done: make(chan struct{})}
+
+		// Only the last output of the job is loaded into memory
+		if j.stdout, j.stderr, err = openOutput(st.jobDir(r.ID), r.Retention); err != nil {
+			return nil, err
+		}
+
+		if !j.finished() {
+			if j.cmd, err = st.recoverCmd(r); err == nil {
+				// The job might have been paused, which is not recorded
+				if frozen, err := j.cmd.Cgroup().Frozen(); err == nil && frozen && r.Status.ParentID == "" {
+					j.status.Status = StatusPaused
+				} else {
+					j.status.Status = StatusStarted
+				}
+
+				j.logs = followLogs(st.jobDir(r.ID), j.stdout, j.stderr)
+				recovered[r.ID] = j
+			} else {
+				// The processes left behind by the job are killed, unless
+				// they share the cgroup of its parent, failing to do so
+				// leaves them running on their own
+				if r.Status.ParentID == "" {
+					_ = resourcecontrol.Discard(r.ID)
+				}
+
+				now := time.Now()
+				j.status.Status = StatusStopped
+				j.status.EndTime = now
+				j.endAlerts(now)
+
+				if err := st.save(record{ID: r.ID, Status: j.status, Retention: r.Retention}); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		// The recovered jobs are finished by their wait goroutine
+		if j.finished() {
+			close(j.done)
+			j.closeOutput(nil)
+		}
+
+		s.processes[r.ID] = j
+	}
+
+	// The recovered jobs are resumed once every job is registered, since they
+	// might finish right away, and from then on the lock must be held
+	for id, j := range recovered {
+		s.resume(id, j)
+	}
+
+	return s, nil
+}
+
+// recoverCmd returns the command of a job recorded as running, which was run
+// under a monitor unless it had a terminal. The jobs started by ExecJob run in
+// the cgroup of their parent.
+func (st *store) recoverCmd(r record) (*resourcecontrol.Cmd, error) {
+	if r.MonitorPID == 0 {
+		return nil, resourcecontrol.ErrNoPID
+	}
+
+	cgroupName := r.ID
+	if r.Status.ParentID != "" {
+		cgroupName = r.Status.ParentID
+	}
+
+	return resourcecontrol.Recover(st.jobDir(r.ID), cgroupName, r.Status.ParentID != "", r.MonitorPID)
+}
+
+// jobRecord returns the record of the current status of the job, it must be
+// called with the lock held once the job is registered
+func (s *Supervisor) jobRecord(id string, j *Job) record {
+	r := record{ID: id, Status: j.status, Retention: j.retention}
+	if j.cmd != nil {
+		r.MonitorPID = j.cmd.MonitorPID()
+	}
+
+	return r
+}
+
+// saveRecord appends the given record to the journal, if any, it is called
+// without holding the lock since the journal is written to disk
+func (s *Supervisor) saveRecord(r record) error {
+	if s.store == nil {
+		return nil
+	}
+
+	return s.store.save(r)
+}
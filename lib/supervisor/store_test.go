@@ -0,0 +1,195 @@
+package supervisor
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestJournal(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Truncate(time.Second)
+
+	st, records, err := openStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(records) != 0 {
+		t.Fatalf("expected no records, got %d", len(records))
+	}
+
+	for _, r := range []record{
+		{ID: "job-1", Status: Status{Status: StatusStarted, Owner: "user", StartTime: start.Add(time.Second)}},
+		{ID: "job-0", Status: Status{Status: StatusStarted, Owner: "user", StartTime: start}},
+		{ID: "job-1", Status: Status{Status: StatusDone, ExitCode: 3, Owner: "user", StartTime: start.Add(time.Second)}},
+	} {
+		if err := st.save(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A record cut short by a crash is ignored
+	if _, err := st.f.Write([]byte(`{"id":"job-2","sta`)); err != nil {
+		t.Fatal(err)
+	}
+	st.f.Close()
+
+	if _, records, err = openStore(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	if records[0].ID != "job-0" || records[0].Status.Status != StatusStarted {
+		t.Errorf("expected 'job-0' started, got '%+v'", records[0])
+	}
+
+	if records[1].ID != "job-1" || records[1].Status.Status != StatusDone || records[1].Status.ExitCode != 3 {
+		t.Errorf("expected 'job-1' done, got '%+v'", records[1])
+	}
+
+	// The journal was compacted
+	if records, err = readJournal(path.Join(dir, journalFile)); err != nil {
+		t.Fatal(err)
+	} else if len(records) != 2 {
+		t.Errorf("expected 2 records, got %d", len(records))
+	}
+
+	if err := os.WriteFile(path.Join(dir, journalFile), []byte("not json\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := openStore(dir); err == nil {
+		t.Error("expected an error for an invalid journal")
+	}
+}
+
+func TestNewSupervisorWithState(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Minute)
+
+	if err := writeJournal(path.Join(dir, journalFile), []record{
+		{ID: "running", Status: Status{Status: StatusStarted, Owner: "user", StartTime: start}},
+		{ID: "done", Status: Status{Status: StatusDone, ExitCode: 1, Owner: "user", StartTime: start, EndTime: start.Add(time.Second)}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	sup, err := NewSupervisorWithState(dir, DefaultAlertThresholds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := sup.JobStatus("done")
+	if err != nil {
+		t.Fatal(err)
+	} else if status.Status != StatusDone || status.ExitCode != 1 || status.Owner != "user" {
+		t.Errorf("expected the recorded status, got '%+v'", status)
+	}
+
+	status, err = sup.JobStatus("running")
+	if err != nil {
+		t.Fatal(err)
+	} else if status.Status != StatusStopped || status.EndTime.IsZero() {
+		t.Errorf("expected a stopped job, got '%+v'", status)
+	}
+
+	jobs, _, err := sup.ListJobs(JobFilter{Owner: "user"}, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(jobs) != 2 {
+		t.Errorf("expected 2 jobs, got %d", len(jobs))
+	}
+
+	if err := sup.StopJob("done"); err != ErrJobFinished {
+		t.Errorf("expected '%s', got '%v'", ErrJobFinished, err)
+	}
+
+	if _, _, err := sup.JobTerminal("done", false); err != ErrNoTTY {
+		t.Errorf("expected '%s', got '%v'", ErrNoTTY, err)
+	}
+
+	rd, err := sup.JobStdOut("done")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out, err := io.ReadAll(rd); err != nil || len(out) > 0 {
+		t.Errorf("expected no output, got '%s' (%v)", out, err)
+	}
+
+	if _, err := sup.WaitJob(context.Background(), "running"); err != nil {
+		t.Error(err)
+	}
+
+	// The stopped job was recorded as such
+	records, err := readJournal(path.Join(dir, journalFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range records {
+		if r.ID == "running" && r.Status.Status != StatusStopped {
+			t.Errorf("expected a stopped job, got '%+v'", r)
+		}
+	}
+}
+
+func TestRecoverJobs(t *testing.T) {
+	dir := t.TempDir()
+
+	sup, err := NewSupervisorWithState(dir, DefaultAlertThresholds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := sup.StartJob("sh", "-c", "echo before; sleep 0.5; echo after; exit 4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := sup.JobStatus(id)
+	if err != nil {
+		t.Fatal(err)
+	} else if status.Status != StatusStarted {
+		t.Fatalf("expected a started job, got '%+v'", status)
+	}
+
+	// The job keeps running without the first supervisor, which is only left
+	// to reap its monitor
+	recovered, err := NewSupervisorWithState(dir, DefaultAlertThresholds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if st, err := recovered.JobStatus(id); err != nil {
+		t.Fatal(err)
+	} else if st.Status != StatusStarted || st.PID != status.PID {
+		t.Errorf("expected a started job with PID %d, got '%+v'", status.PID, st)
+	}
+
+	rd, err := recovered.JobStdOut(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := recovered.WaitJob(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	} else if st.Status != StatusDone || st.ExitCode != 4 {
+		t.Errorf("expected 'exit status 4', got '%+v'", st)
+	}
+
+	expected := "before\nafter\n"
+	if out, err := io.ReadAll(rd); err == nil || string(out) != expected {
+		t.Errorf("expected '%s' and an exit error, got '%s' (%v)", expected, out, err)
+	}
+
+	if _, err := sup.WaitJob(context.Background(), id); err != nil {
+		t.Error(err)
+	}
+}
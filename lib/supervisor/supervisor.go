@@ -1,50 +1,148 @@
 package supervisor
 
 import (
+	"context"
 	"errors"
+	"io"
 	"io/ioutil"
-	"os/exec"
+	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/andres-teleport/overseer/lib/multipipe"
 	"github.com/andres-teleport/overseer/lib/resourcecontrol"
+	"golang.org/x/sys/unix"
 )
 
 var (
 	ErrUnknownJobID = errors.New("unknown job ID")
 	ErrJobFinished  = errors.New("job was already finished")
+	ErrJobStopping  = errors.New("job is already being stopped")
+	ErrJobPaused    = errors.New("job is already paused")
+	ErrJobNotPaused = errors.New("job is not paused")
+	ErrJobBusy      = errors.New("job is being paused, resumed or is starting a process")
+	ErrNoStdin      = errors.New("job was started without a standard input")
+	ErrNoTTY        = errors.New("job was started without a terminal")
+	ErrStdinWithTTY = errors.New("a job with a terminal reads its input from it")
+	ErrSharedCgroup = errors.New("operation not supported on a process sharing the cgroup of its job")
 )
 
 const (
 	StatusStarted = iota
 	StatusDone
 	StatusStopped
+	StatusPaused
 )
 
 type Status struct {
 	Status   int
 	ExitCode int
+
+	// StopSignal is the signal sent by StopJob that ended the job, if any
+	StopSignal unix.Signal
+
+	// Signal is the signal that terminated the job, if any, CoreDumped tells
+	// whether it produced a core dump and OOMKilled whether the OOM killer
+	// killed any of the processes of the job
+	Signal     unix.Signal
+	CoreDumped bool
+	OOMKilled  bool
+
+	// PID is the host PID of the main process of the job, Command, Arguments,
+	// Labels and Owner the ones it was started with
+	PID       int
+	Command   string
+	Arguments []string
+	Labels    map[string]string
+	Owner     string
+
+	// ParentID is the ID of the job whose cgroup is shared by this one, if it
+	// was started by ExecJob
+	ParentID string
+
+	// StartTime is when the job was started and EndTime when it finished, it
+	// is zero while the job is running
+	StartTime time.Time
+	EndTime   time.Time
+
+	// Alerts are the latest alerts raised for the job, the ones still active
+	// have no End
+	Alerts []Alert
+}
+
+// Duration returns how long the job ran, or has been running so far
+func (s Status) Duration() time.Duration {
+	if s.EndTime.IsZero() {
+		return time.Since(s.StartTime)
+	}
+
+	return s.EndTime.Sub(s.StartTime)
 }
 
-// TODO: add option to set the environment variables
 type Job struct {
-	cmd    *resourcecontrol.Cmd
-	status Status
-	stdout *multipipe.MultiPipe
-	stderr *multipipe.MultiPipe
+	// cmd is nil for the jobs loaded from the journal of a previous supervisor
+	cmd      *resourcecontrol.Cmd
+	status   Status
+	stopping bool
+	stdin    io.WriteCloser
+	stdout   *multipipe.MultiPipe
+	stderr   *multipipe.MultiPipe
+
+	// logs copies the output files of a job run under a monitor to stdout
+	// and stderr, which keep the output selected by retention
+	logs      *outputLogs
+	retention multipipe.Retention
+
+	// freezing is true while the cgroup of the job is being frozen or thawed,
+	// which is done without holding the lock as it can take a while, and
+	// execs is the number of jobs being started in its cgroup by ExecJob,
+	// which must not be frozen or killed before they join it
+	freezing bool
+	execs    int
+
+	// done is closed once the job has finished and its final status is known
+	done chan struct{}
+}
+
+// finished returns true if the processes of the job are no longer running
+func (j *Job) finished() bool {
+	return j.status.Status != StatusStarted && j.status.Status != StatusPaused
+}
+
+// closeOutput closes the output streams of the job with the given error, which
+// is returned to their readers once they reach the end
+func (j *Job) closeOutput(err error) {
+	j.stdout.CloseWithError(err)
+	j.stderr.CloseWithError(err)
 }
 
 type Supervisor struct {
+	// AlertThresholds are the thresholds of the alerts of the jobs started
+	// from then on, DefaultAlertThresholds by default
+	AlertThresholds AlertThresholds
+
+	// OutputDir is the directory where the output of the jobs started from
+	// then on is stored, in a directory per job, instead of in memory. Only
+	// the last output of each job is kept in memory. The jobs recorded in a
+	// state directory store their output there. The directories are never
+	// removed, their size is only bounded by the OutputRetention of the jobs.
+	OutputDir string
+
 	mu        sync.Mutex
 	processes map[string]*Job
+	events    eventBus
+
+	// store records the jobs in the state directory, if any
+	store *store
 }
 
 // NewSupervisor returns a Supervisor struct that will allow starting, stopping
 // and operating with jobs
 func NewSupervisor() *Supervisor {
 	return &Supervisor{
-		processes: make(map[string]*Job),
+		AlertThresholds: DefaultAlertThresholds,
+		processes:       make(map[string]*Job),
 	}
 }
 
@@ -60,80 +158,428 @@ func (s *Supervisor) jobApplyFn(id string, jobFn func(*Job)) error {
 	return ErrUnknownJobID
 }
 
-// StartJob runs the given command and arguments, enforcing resource controls.
-// Returns a UUID to identify the job or an error on failure.
+// DefaultLimits are the resource limits applied by StartJob
+var DefaultLimits = resourcecontrol.ResourceLimits{
+	CPU:        100, // 10 %
+	Memory:     128 << 20,
+	IOReadBps:  5000000,
+	IOWriteBps: 5000000,
+}
+
+// JobSpec describes the command to be run by a job and its resource limits,
+// Labels are arbitrary key-value pairs that can be used to filter the jobs and
+// Owner identifies the user that started it
+type JobSpec struct {
+	Command   string
+	Arguments []string
+	Limits    resourcecontrol.ResourceLimits
+	Labels    map[string]string
+	Owner     string
+
+	// EnvPolicy selects the variables of the supervisor inherited by the job,
+	// Env holds the ones set for it and Dir is its working directory, the
+	// current one if empty
+	EnvPolicy resourcecontrol.EnvPolicy
+	Env       map[string]string
+	Dir       string
+
+	// Stdin opens a pipe for the standard input of the job, otherwise it
+	// reads from the null device
+	Stdin bool
+
+	// TTY runs the job with a pseudo-terminal as its standard streams, its
+	// output goes to the standard output of the job and it can be attached
+	// to through JobTerminal. It cannot be combined with Stdin.
+	TTY bool
+
+	// OutputRetention selects the output of the job that is kept, all of it
+	// by default. The job is stopped once its output goes past the limit.
+	OutputRetention multipipe.Retention
+}
+
+// StartJob runs the given command and arguments with the DefaultLimits. Returns
+// a UUID to identify the job or an error on failure.
 func (s *Supervisor) StartJob(cmd string, args ...string) (string, error) {
+	return s.StartJobSpec(JobSpec{
+		Command:   cmd,
+		Arguments: args,
+		Limits:    DefaultLimits,
+	})
+}
+
+// StartJobSpec runs the command described by the given spec, enforcing its
+// resource limits in a leaf cgroup named after the job. Returns a UUID to
+// identify the job or an error on failure.
+func (s *Supervisor) StartJobSpec(spec JobSpec) (string, error) {
+	return s.startJob(spec, resourcecontrol.Command(spec.Limits, spec.Command, spec.Arguments...), "")
+}
+
+// ExecJob runs the command described by the given spec as a new job sharing
+// the cgroup, and so the resource limits, of the running job with the given
+// ID, which must not be paused. The limits and owner of the spec are ignored,
+// the new job is owned by the owner of the parent job. It is killed along with
+// the parent job, and the operations affecting the whole cgroup, such as
+// pausing it, are not supported on it. Returns a UUID to identify the new job
+// or an error on failure.
+func (s *Supervisor) ExecJob(parentID string, spec JobSpec) (string, error) {
+	var (
+		cgroup   *resourcecontrol.Cgroup
+		innerErr error
+	)
+
+	if err := s.jobApplyFn(parentID, func(j *Job) {
+		switch {
+		case j.finished():
+			innerErr = ErrJobFinished
+		case j.stopping:
+			innerErr = ErrJobStopping
+		case j.freezing:
+			innerErr = ErrJobBusy
+		case j.status.Status == StatusPaused:
+			innerErr = ErrJobPaused
+		default:
+			j.execs++
+			cgroup = j.cmd.Cgroup()
+			spec.Owner = j.status.Owner
+		}
+	}); err != nil {
+		return "", err
+	} else if innerErr != nil {
+		return "", innerErr
+	}
+
+	// The new job has joined the cgroup once it is started, or failed to
+	id, err := s.startJob(spec, resourcecontrol.CommandIn(cgroup, spec.Command, spec.Arguments...), parentID)
+	_ = s.jobApplyFn(parentID, func(j *Job) {
+		j.execs--
+	})
+
+	return id, err
+}
+
+// startJob starts the given command as a new job described by the spec, with
+// the job given by parentID as its parent if not empty
+func (s *Supervisor) startJob(spec JobSpec, cmd *resourcecontrol.Cmd, parentID string) (string, error) {
+	if spec.TTY && spec.Stdin {
+		return "", ErrStdinWithTTY
+	} else if err := spec.OutputRetention.Validate(); err != nil {
+		return "", err
+	}
+
 	job := &Job{
-		cmd: resourcecontrol.Command(
-			// TODO: make configurable
-			resourcecontrol.ResourceLimits{
-				CPUMax:    "10000 100000", // 10 %
-				MemMax:    "128M",
-				IOMaxRbps: "5000000",
-				IOMaxWbps: "5000000",
-			},
-			cmd,
-			args...,
-		),
+		cmd: cmd,
 		status: Status{
-			Status: StatusStarted,
+			Status:    StatusStarted,
+			Command:   spec.Command,
+			Arguments: spec.Arguments,
+			Labels:    spec.Labels,
+			Owner:     spec.Owner,
+			ParentID:  parentID,
 		},
-		stdout: multipipe.NewMultiPipe(),
-		stderr: multipipe.NewMultiPipe(),
+		retention: spec.OutputRetention,
+		done:      make(chan struct{}),
 	}
-	job.cmd.Stdout = job.stdout
-	job.cmd.Stderr = job.stderr
 
-	if err := job.cmd.Start(); err != nil {
+	uuid, err := ioutil.ReadFile("/proc/sys/kernel/random/uuid")
+	if err != nil {
 		return "", err
 	}
+	id := strings.TrimSpace(string(uuid))
 
-	uuid, err := ioutil.ReadFile("/proc/sys/kernel/random/uuid")
+	job.cmd.CgroupName = id
+	job.cmd.Dir = spec.Dir
+	job.cmd.EnvPolicy = spec.EnvPolicy
+	job.cmd.SetEnv(spec.Env)
+	job.cmd.TTY = spec.TTY
+
+	var logFiles []*os.File
+	switch dir := s.outputDir(id); {
+	case s.store != nil && !spec.TTY:
+		// The jobs recorded in a state directory outlive the supervisor,
+		// so they write their output to files there instead of to pipes
+		stdout, stderr, err := openLogs(dir)
+		if err != nil {
+			return "", err
+		}
+		logFiles = []*os.File{stdout, stderr}
+
+		if job.stdout, job.stderr, err = openOutput(dir, job.retention); err != nil {
+			stdout.Close()
+			stderr.Close()
+			return "", err
+		}
+
+		job.cmd.StateDir = dir
+		job.cmd.Stdout, job.cmd.Stderr = stdout, stderr
+	case dir != "":
+		if job.stdout, job.stderr, err = createOutput(dir, job.retention); err != nil {
+			return "", err
+		}
+
+		job.cmd.Stdout, job.cmd.Stderr = job.stdout, job.stderr
+	default:
+		job.stdout = multipipe.NewMultiPipeWithRetention(job.retention)
+		job.stderr = multipipe.NewMultiPipeWithRetention(job.retention)
+		job.cmd.Stdout, job.cmd.Stderr = job.stdout, job.stderr
+	}
+
+	if spec.Stdin {
+		if job.stdin, err = job.cmd.StdinPipe(); err != nil {
+			job.closeOutput(err)
+			return "", err
+		}
+	}
+
+	err = job.cmd.Start()
+	for _, f := range logFiles {
+		// The processes of the job hold their own copies
+		f.Close()
+	}
 	if err != nil {
+		job.closeOutput(err)
+		return "", err
+	}
+	job.status.PID = job.cmd.Pid()
+	job.status.StartTime = time.Now()
+
+	if job.cmd.StateDir != "" {
+		job.logs = followLogs(job.cmd.StateDir, job.stdout, job.stderr)
+	}
+
+	// The job is not visible to the other goroutines until it is registered,
+	// so it is recorded without holding the lock
+	if err := s.saveRecord(s.jobRecord(id, job)); err != nil {
+		_ = job.cmd.Kill()
+		_ = job.cmd.Wait()
+		if job.logs != nil {
+			job.logs.close()
+		}
+		job.closeOutput(err)
 		return "", err
 	}
-	id := strings.TrimSpace(string(uuid))
 
 	s.mu.Lock()
 	s.processes[id] = job
+	s.publish(EventStarted, id, job)
 	s.mu.Unlock()
 
+	s.resume(id, job)
+
+	return id, nil
+}
+
+// resume starts monitoring the running job with the given ID, which must be
+// registered already, and waits for it in the background, recording its final
+// status once it has finished
+func (s *Supervisor) resume(id string, job *Job) {
+	// The jobs started by ExecJob share the alerts of their parent
+	if job.status.ParentID == "" {
+		go s.monitor(id, job, s.AlertThresholds)
+	}
+
+	if job.retention.Limit > 0 {
+		go s.limitOutput(id, job)
+	}
+
 	go func() {
 		err := job.cmd.Wait()
 
+		if job.logs != nil {
+			job.logs.close()
+		}
+
 		s.mu.Lock()
-		if exitError, ok := err.(*exec.ExitError); ok {
-			job.status.ExitCode = exitError.ExitCode()
+		job.status.EndTime = time.Now()
+		job.endAlerts(job.status.EndTime)
+		if ws, ok := job.cmd.WaitStatus(); ok {
+			job.status.ExitCode = ws.ExitStatus()
+
+			if ws.Signaled() {
+				job.status.Signal = ws.Signal()
+				job.status.CoreDumped = ws.CoreDump()
+			}
 		}
+		job.status.OOMKilled = job.cmd.OOMKilled()
 
-		if job.status.Status != StatusStopped {
+		if job.status.OOMKilled {
+			s.publish(EventOOMKilled, id, job)
+		}
+
+		var finished func()
+		switch {
+		case !job.stopping:
 			job.status.Status = StatusDone
+			finished = s.finish(EventExited, id, job)
+		case job.status.Status == StatusStopped:
+			// StopJobGracefully finished first and left the event to us
+			finished = s.finish(EventStopped, id, job)
 		}
 		s.mu.Unlock()
 
-		job.stdout.CloseWithError(err)
-		job.stderr.CloseWithError(err)
+		if finished != nil {
+			finished()
+		}
+		job.closeOutput(err)
 	}()
+}
 
-	return id, nil
+// finish publishes the final event of the job, it must be called with the lock
+// held. The returned function must be called once the lock is released, as
+// writing to the journal can take a while, it records the final status of the
+// job and then wakes up its waiters.
+func (s *Supervisor) finish(t EventType, id string, j *Job) func() {
+	r := s.jobRecord(id, j)
+	s.publish(t, id, j)
+
+	return func() {
+		// The job has finished anyway, failing to record it only affects
+		// the status reported after a restart
+		_ = s.saveRecord(r)
+
+		close(j.done)
+	}
 }
 
-// StopJob kills the job with the given ID, unless it has already finished,
-// returning an error in that case
+// WaitJob blocks until the job with the given ID has finished and returns its
+// final status, or returns early with an error if the context is done
+func (s *Supervisor) WaitJob(ctx context.Context, id string) (Status, error) {
+	var done chan struct{}
+
+	if err := s.jobApplyFn(id, func(j *Job) {
+		done = j.done
+	}); err != nil {
+		return Status{}, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return Status{}, ctx.Err()
+	case <-done:
+	}
+
+	return s.JobStatus(id)
+}
+
+// StopJob kills the job with the given ID along with all the processes it
+// spawned, unless it has already finished, returning an error in that case.
+// The job is reported as stopped once none of its processes are left.
 func (s *Supervisor) StopJob(id string) error {
-	var innerErr error
+	return s.StopJobGracefully(id, unix.SIGKILL, 0)
+}
+
+// StopJobGracefully sends the given signal to every process of the job with
+// the given ID and waits up to the grace period for them to exit, the ones
+// still running after it are killed. An error is returned if the job has
+// already finished. The job is reported as stopped once none of its processes
+// are left. Only the main process of a job started by ExecJob is stopped.
+func (s *Supervisor) StopJobGracefully(id string, sig unix.Signal, grace time.Duration) error {
+	var (
+		cmd      *resourcecontrol.Cmd
+		thaw     bool
+		innerErr error
+	)
 
 	if err := s.jobApplyFn(id, func(j *Job) {
-		if j.status.Status != StatusStarted {
+		switch {
+		case j.finished():
 			innerErr = ErrJobFinished
-			return
+		case j.stopping:
+			innerErr = ErrJobStopping
+		case j.freezing && sig != unix.SIGKILL, j.execs > 0:
+			innerErr = ErrJobBusy
+		default:
+			j.stopping = true
+			cmd = j.cmd
+
+			// Frozen processes would not handle the signal, killing them
+			// does not need it
+			thaw = j.status.Status == StatusPaused && sig != unix.SIGKILL
+			j.freezing = j.freezing || thaw
+		}
+	}); err != nil {
+		return err
+	} else if innerErr != nil {
+		return innerErr
+	}
+
+	// The lock is not held while stopping, as it can take a while
+	var (
+		stopSignal unix.Signal
+		err        error
+	)
+	if thaw {
+		err = s.thawJob(id, cmd.Cgroup())
+	}
+	if err == nil {
+		stopSignal, err = stopCmd(cmd, sig, grace)
+	}
+	finished := func() {}
+	if err != nil {
+		_ = s.jobApplyFn(id, func(j *Job) {
+			j.stopping = false
+
+			// The job exited meanwhile and left finishing it to us
+			if !j.status.EndTime.IsZero() {
+				j.status.Status = StatusDone
+				finished = s.finish(EventExited, id, j)
+			}
+		})
+		finished()
+
+		return err
+	}
+
+	err = s.jobApplyFn(id, func(j *Job) {
+		j.status.Status = StatusStopped
+		j.status.StopSignal = stopSignal
+
+		// Otherwise the job is finished once the exit status is known
+		if !j.status.EndTime.IsZero() {
+			finished = s.finish(EventStopped, id, j)
+		}
+	})
+	finished()
+
+	return err
+}
+
+// stopCmd sends the given signal to the processes of the command, then kills
+// them if they are still running after the grace period. Returns the signal
+// that ended the command.
+func stopCmd(cmd *resourcecontrol.Cmd, sig unix.Signal, grace time.Duration) (unix.Signal, error) {
+	if sig != unix.SIGKILL {
+		if err := cmd.Signal(sig); err != nil {
+			return 0, err
 		}
 
-		j.status = Status{
-			Status: StatusStopped,
+		if exited, err := cmd.WaitExited(grace); err != nil {
+			return 0, err
+		} else if exited {
+			return sig, nil
 		}
+	}
+
+	return unix.SIGKILL, cmd.Kill()
+}
 
-		innerErr = j.cmd.Process.Kill()
+// SignalJob sends the given signal to the main process of the job with the
+// given ID, or to all of its processes if group is true, which is not supported
+// on the jobs started by ExecJob. An error is returned if the job has already
+// finished.
+func (s *Supervisor) SignalJob(id string, sig unix.Signal, group bool) error {
+	var innerErr error
+
+	if err := s.jobApplyFn(id, func(j *Job) {
+		switch {
+		case j.finished():
+			innerErr = ErrJobFinished
+		case group && j.status.ParentID != "":
+			innerErr = ErrSharedCgroup
+		case group:
+			innerErr = j.cmd.Cgroup().Signal(sig)
+		default:
+			innerErr = j.cmd.SignalProcess(sig)
+		}
 	}); err != nil {
 		return err
 	}
@@ -141,6 +587,109 @@ func (s *Supervisor) StopJob(id string) error {
 	return innerErr
 }
 
+// PauseJob freezes every process of the job with the given ID, keeping their
+// state until ResumeJob is called, which also freezes the jobs started in it by
+// ExecJob. An error is returned if the job is not running or was started by
+// ExecJob itself.
+func (s *Supervisor) PauseJob(id string) error {
+	var (
+		cgroup   *resourcecontrol.Cgroup
+		innerErr error
+	)
+
+	if err := s.jobApplyFn(id, func(j *Job) {
+		switch {
+		case j.status.ParentID != "":
+			innerErr = ErrSharedCgroup
+		case j.finished():
+			innerErr = ErrJobFinished
+		case j.stopping:
+			innerErr = ErrJobStopping
+		case j.freezing, j.execs > 0:
+			innerErr = ErrJobBusy
+		case j.status.Status == StatusPaused:
+			innerErr = ErrJobPaused
+		default:
+			j.freezing = true
+			cgroup = j.cmd.Cgroup()
+		}
+	}); err != nil {
+		return err
+	} else if innerErr != nil {
+		return innerErr
+	}
+
+	// The lock is not held while freezing, as it waits for every process
+	err := cgroup.Freeze()
+
+	_ = s.jobApplyFn(id, func(j *Job) {
+		j.freezing = false
+
+		switch {
+		case err != nil:
+		case j.finished():
+			err = ErrJobFinished
+		case j.stopping:
+			// The job is being killed, which thaws it
+			err = ErrJobStopping
+		default:
+			j.status.Status = StatusPaused
+			s.publish(EventPaused, id, j)
+		}
+	})
+
+	return err
+}
+
+// ResumeJob thaws the processes of the job with the given ID, returning an
+// error if the job is not paused or was started by ExecJob
+func (s *Supervisor) ResumeJob(id string) error {
+	var (
+		cgroup   *resourcecontrol.Cgroup
+		innerErr error
+	)
+
+	if err := s.jobApplyFn(id, func(j *Job) {
+		switch {
+		case j.status.ParentID != "":
+			innerErr = ErrSharedCgroup
+		case j.finished():
+			innerErr = ErrJobFinished
+		case j.freezing:
+			innerErr = ErrJobBusy
+		case j.status.Status != StatusPaused:
+			innerErr = ErrJobNotPaused
+		default:
+			j.freezing = true
+			cgroup = j.cmd.Cgroup()
+		}
+	}); err != nil {
+		return err
+	} else if innerErr != nil {
+		return innerErr
+	}
+
+	return s.thawJob(id, cgroup)
+}
+
+// thawJob thaws the given cgroup of the job with the given ID, which must be
+// marked as freezing, without holding the lock, then records that the job is
+// running again
+func (s *Supervisor) thawJob(id string, cgroup *resourcecontrol.Cgroup) error {
+	err := cgroup.Thaw()
+
+	_ = s.jobApplyFn(id, func(j *Job) {
+		j.freezing = false
+
+		if err == nil && !j.finished() {
+			j.status.Status = StatusStarted
+			s.publish(EventResumed, id, j)
+		}
+	})
+
+	return err
+}
+
 // JobStatus returns the status of the job with the given ID, or an error if the
 // job was not found
 func (s *Supervisor) JobStatus(id string) (status Status, err error) {
@@ -151,6 +700,31 @@ func (s *Supervisor) JobStatus(id string) (status Status, err error) {
 	return
 }
 
+// JobStats returns the current resource usage of the cgroup of the job with the
+// given ID, which is shared with its parent for the jobs started by ExecJob.
+// An error is returned if the job has already finished.
+func (s *Supervisor) JobStats(id string) (resourcecontrol.Stats, error) {
+	var cgroup *resourcecontrol.Cgroup
+
+	if err := s.jobApplyFn(id, func(j *Job) {
+		if !j.finished() {
+			cgroup = j.cmd.Cgroup()
+		}
+	}); err != nil {
+		return resourcecontrol.Stats{}, err
+	} else if cgroup == nil {
+		return resourcecontrol.Stats{}, ErrJobFinished
+	}
+
+	// The job might finish while reading
+	st, err := cgroup.Stats()
+	if err == resourcecontrol.ErrCgroupRemoved {
+		err = ErrJobFinished
+	}
+
+	return st, err
+}
+
 // JobStdOut returns an io.Reader corresponding to the standard output of the
 // job with the given ID, or an error if the job was not found
 func (s *Supervisor) JobStdOut(id string) (rd *multipipe.Reader, err error) {
@@ -161,6 +735,45 @@ func (s *Supervisor) JobStdOut(id string) (rd *multipipe.Reader, err error) {
 	return
 }
 
+// JobStdIn returns the standard input of the job with the given ID, closing it
+// signals the end of the input to the job. An error is returned if the job was
+// not found or was started without a standard input.
+func (s *Supervisor) JobStdIn(id string) (w io.WriteCloser, err error) {
+	err = s.jobApplyFn(id, func(j *Job) {
+		w = j.stdin
+	})
+
+	if err == nil && w == nil {
+		err = ErrNoStdin
+	}
+
+	return
+}
+
+// JobTerminal returns the terminal of the job with the given ID, to send input
+// to it and resize it, along with a reader of its output from now on, or from
+// the start if replay is true. An error is returned if the job was not found or
+// was started without a terminal.
+func (s *Supervisor) JobTerminal(id string, replay bool) (term *resourcecontrol.Terminal, rd *multipipe.Reader, err error) {
+	err = s.jobApplyFn(id, func(j *Job) {
+		if j.cmd == nil {
+			return
+		} else if term = j.cmd.Terminal(); term == nil {
+			return
+		} else if replay {
+			rd = j.stdout.NewReader()
+		} else {
+			rd = j.stdout.NewTailReader()
+		}
+	})
+
+	if err == nil && term == nil {
+		err = ErrNoTTY
+	}
+
+	return
+}
+
 // JobStdErr returns an io.Reader corresponding to the standard error of the job
 // with the given ID, or an error if the job was not found
 func (s *Supervisor) JobStdErr(id string) (rd *multipipe.Reader, err error) {
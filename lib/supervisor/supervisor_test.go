@@ -2,8 +2,15 @@ package supervisor
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"os"
+	"path"
 	"testing"
+	"time"
+
+	"github.com/andres-teleport/overseer/lib/multipipe"
+	"golang.org/x/sys/unix"
 )
 
 func TestFailedStart(t *testing.T) {
@@ -45,6 +52,549 @@ func TestStartStopStatus(t *testing.T) {
 	}
 }
 
+func TestStopKillsDescendants(t *testing.T) {
+	sup := NewSupervisor()
+
+	// The background process keeps the standard output open
+	jobID, err := sup.StartJob("sh", "-c", "sleep 999 & sleep 999")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rd, err := sup.JobStdOut(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sup.StopJob(jobID); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, rd)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("the standard output was not closed")
+	}
+
+	status, err := sup.JobStatus(jobID)
+	if err != nil {
+		t.Fatal(err)
+	} else if status.Status != StatusStopped {
+		t.Errorf("StatusStopped expected, %d got", status.Status)
+	}
+}
+
+func TestStopGracefully(t *testing.T) {
+	sup := NewSupervisor()
+
+	var tests = []struct {
+		script             string
+		expectedStopSignal unix.Signal
+	}{
+		{"trap 'exit 0' TERM; while true; do sleep 0.1; done", unix.SIGTERM},
+		{"trap '' TERM; while true; do sleep 0.1; done", unix.SIGKILL},
+	}
+
+	for _, tt := range tests {
+		jobID, err := sup.StartJob("sh", "-c", tt.script)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Give the shell some time to set up the trap
+		time.Sleep(200 * time.Millisecond)
+
+		if err := sup.StopJobGracefully(jobID, unix.SIGTERM, time.Second); err != nil {
+			t.Fatal(err)
+		}
+
+		status, err := sup.JobStatus(jobID)
+		if err != nil {
+			t.Fatal(err)
+		} else if status.Status != StatusStopped {
+			t.Errorf("StatusStopped expected, %d got", status.Status)
+		} else if status.StopSignal != tt.expectedStopSignal {
+			t.Errorf("expected '%s', got '%s'", tt.expectedStopSignal, status.StopSignal)
+		}
+	}
+}
+
+func TestSignal(t *testing.T) {
+	sup := NewSupervisor()
+
+	jobID, err := sup.StartJob("sh", "-c", "trap 'echo hup' HUP; while true; do sleep 0.1; done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sup.StopJob(jobID)
+
+	// Give the shell some time to set up the trap
+	time.Sleep(200 * time.Millisecond)
+
+	if err := sup.SignalJob(jobID, unix.SIGHUP, false); err != nil {
+		t.Fatal(err)
+	}
+
+	rd, err := sup.JobStdOut(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 16)
+	n, err := rd.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out := bytes.TrimSpace(buf[:n]); string(out) != "hup" {
+		t.Errorf("expected 'hup', got '%s'", out)
+	}
+
+	if err := sup.SignalJob("fake-id", unix.SIGHUP, true); err != ErrUnknownJobID {
+		t.Errorf("expected '%s', got '%v'", ErrUnknownJobID, err)
+	}
+}
+
+func TestTerminationSignal(t *testing.T) {
+	sup := NewSupervisor()
+
+	jobID, err := sup.StartJob("sh", "-c", "kill -TERM $$")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rd, err := sup.JobStdOut(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, rd)
+
+	status, err := sup.JobStatus(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.Signal != unix.SIGTERM {
+		t.Errorf("expected '%s', got '%s'", unix.SIGTERM, status.Signal)
+	}
+
+	if status.ExitCode != -1 || status.CoreDumped || status.OOMKilled {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestStatusDetails(t *testing.T) {
+	sup := NewSupervisor()
+
+	jobID, err := sup.StartJob("sleep", "0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := sup.JobStatus(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.Command != "sleep" || len(status.Arguments) != 1 || status.Arguments[0] != "0.2" {
+		t.Errorf("expected 'sleep 0.2', got '%s %v'", status.Command, status.Arguments)
+	}
+
+	if status.PID <= 0 || status.StartTime.IsZero() || !status.EndTime.IsZero() {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	rd, err := sup.JobStdOut(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, rd)
+
+	if status, _ = sup.JobStatus(jobID); status.EndTime.IsZero() {
+		t.Errorf("expected an end time, got none")
+	} else if d := status.Duration(); d < 200*time.Millisecond {
+		t.Errorf("expected a duration of at least 200ms, got %s", d)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	sup := NewSupervisor()
+
+	jobID, err := sup.StartJob("sh", "-c", "sleep 0.5; echo done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sup.StopJob(jobID)
+
+	if err := sup.ResumeJob(jobID); err != ErrJobNotPaused {
+		t.Errorf("expected '%s', got '%v'", ErrJobNotPaused, err)
+	}
+
+	if err := sup.PauseJob(jobID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sup.PauseJob(jobID); err != ErrJobPaused {
+		t.Errorf("expected '%s', got '%v'", ErrJobPaused, err)
+	}
+
+	// The job would have finished by now if it was not frozen
+	time.Sleep(time.Second)
+
+	if status, _ := sup.JobStatus(jobID); status.Status != StatusPaused {
+		t.Errorf("StatusPaused expected, %d got", status.Status)
+	}
+
+	if err := sup.ResumeJob(jobID); err != nil {
+		t.Fatal(err)
+	}
+
+	rd, err := sup.JobStdOut(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := io.ReadAll(rd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out := bytes.TrimSpace(out); string(out) != "done" {
+		t.Errorf("expected 'done', got '%s'", out)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	sup := NewSupervisor()
+
+	sub := sup.Subscribe()
+	defer sub.Close()
+
+	jobID, err := sup.StartJobSpec(JobSpec{
+		Command:   "sleep",
+		Arguments: []string{"999"},
+		Limits:    DefaultLimits,
+		Owner:     "user",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sup.PauseJob(jobID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sup.ResumeJob(jobID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sup.StopJob(jobID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []EventType{EventStarted, EventPaused, EventResumed, EventStopped} {
+		select {
+		case ev := <-sub.C:
+			if ev.Type != expected {
+				t.Errorf("expected '%s', got '%s'", expected, ev.Type)
+			}
+
+			if ev.JobID != jobID || ev.Status.Owner != "user" {
+				t.Errorf("unexpected event %+v", ev)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected '%s', got none", expected)
+		}
+	}
+}
+
+func TestWaitJob(t *testing.T) {
+	sup := NewSupervisor()
+
+	jobID, err := sup.StartJob("sh", "-c", "sleep 0.2; exit 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := sup.WaitJob(context.Background(), jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.Status != StatusDone || status.ExitCode != 3 {
+		t.Errorf("expected 'Done = 3', got '%d = %d'", status.Status, status.ExitCode)
+	}
+
+	// A job being stopped is waited for until it is reported as stopped
+	jobID, err = sup.StartJob("sleep", "999")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := sup.WaitJob(ctx, jobID); err != context.DeadlineExceeded {
+		t.Errorf("expected '%s', got '%v'", context.DeadlineExceeded, err)
+	}
+
+	go sup.StopJob(jobID)
+
+	if status, err = sup.WaitJob(context.Background(), jobID); err != nil {
+		t.Fatal(err)
+	} else if status.Status != StatusStopped || status.Signal != unix.SIGKILL {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	if _, err := sup.WaitJob(context.Background(), "fake-id"); err != ErrUnknownJobID {
+		t.Errorf("expected '%s', got '%v'", ErrUnknownJobID, err)
+	}
+}
+
+func TestStdIn(t *testing.T) {
+	sup := NewSupervisor()
+	testString := "hello stdin"
+
+	jobID, err := sup.StartJobSpec(JobSpec{
+		Command: "cat",
+		Limits:  DefaultLimits,
+		Stdin:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdin, err := sup.JobStdIn(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.WriteString(stdin, testString); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stdin.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rd, err := sup.JobStdOut(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := io.ReadAll(rd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(out) != testString {
+		t.Errorf("expected '%s', got '%s'", testString, out)
+	}
+
+	// Jobs without a standard input read from the null device
+	jobID, err = sup.StartJob("cat")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sup.JobStdIn(jobID); err != ErrNoStdin {
+		t.Errorf("expected '%s', got '%v'", ErrNoStdin, err)
+	}
+
+	if status, err := sup.WaitJob(context.Background(), jobID); err != nil {
+		t.Fatal(err)
+	} else if status.ExitCode != 0 {
+		t.Errorf("expected 0, got %d", status.ExitCode)
+	}
+}
+
+func TestExecJob(t *testing.T) {
+	sup := NewSupervisor()
+
+	parentID, err := sup.StartJobSpec(JobSpec{
+		Command:   "sleep",
+		Arguments: []string{"10"},
+		Limits:    DefaultLimits,
+		Owner:     "user",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The process runs in the cgroup of its parent job
+	jobID, err := sup.ExecJob(parentID, JobSpec{Command: "cat", Arguments: []string{"/proc/self/cgroup"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := sup.WaitJob(context.Background(), jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.ParentID != parentID || status.Owner != "user" {
+		t.Errorf("expected '%s' and 'user', got '%s' and '%s'", parentID, status.ParentID, status.Owner)
+	}
+
+	rd, err := sup.JobStdOut(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := io.ReadAll(rd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasSuffix(bytes.TrimSpace(out), []byte("/"+parentID)) {
+		t.Errorf("expected '.../%s', got '%s'", parentID, out)
+	}
+
+	// Stopping it leaves its parent running
+	jobID, err = sup.ExecJob(parentID, JobSpec{Command: "sleep", Arguments: []string{"10"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sup.PauseJob(jobID); err != ErrSharedCgroup {
+		t.Errorf("expected '%s', got '%v'", ErrSharedCgroup, err)
+	}
+
+	if err := sup.StopJobGracefully(jobID, unix.SIGTERM, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if status, err := sup.WaitJob(context.Background(), jobID); err != nil {
+		t.Fatal(err)
+	} else if status.Status != StatusStopped || status.Signal != unix.SIGTERM {
+		t.Errorf("expected %d (%s), got %d (%s)", StatusStopped, unix.SIGTERM, status.Status, status.Signal)
+	}
+
+	if status, err := sup.JobStatus(parentID); err != nil {
+		t.Fatal(err)
+	} else if status.Status != StatusStarted {
+		t.Errorf("expected %d, got %d", StatusStarted, status.Status)
+	}
+
+	// Nothing can be run in a finished job
+	if err := sup.StopJob(parentID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sup.ExecJob(parentID, JobSpec{Command: "true"}); err != ErrJobFinished {
+		t.Errorf("expected '%s', got '%v'", ErrJobFinished, err)
+	}
+}
+
+func TestJobStats(t *testing.T) {
+	sup := NewSupervisor()
+
+	jobID, err := sup.StartJob("sleep", "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := sup.JobStats(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if st.Time.IsZero() || st.CPU.UsageUsec <= 0 {
+		t.Errorf("expected some CPU usage, got '%+v'", st)
+	}
+
+	if err := sup.StopJob(jobID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sup.JobStats(jobID); err != ErrJobFinished {
+		t.Errorf("expected '%s', got '%v'", ErrJobFinished, err)
+	}
+}
+
+// readUntil reads from rd until its output contains the given string
+func readUntil(t *testing.T, rd io.Reader, s string) string {
+	var out []byte
+	buf := make([]byte, 1024)
+
+	for !bytes.Contains(out, []byte(s)) {
+		n, err := rd.Read(buf)
+		out = append(out, buf[:n]...)
+
+		if err != nil {
+			t.Fatalf("expected '%s', got '%s' (%s)", s, out, err)
+		}
+	}
+
+	return string(out)
+}
+
+func TestTTY(t *testing.T) {
+	sup := NewSupervisor()
+
+	if _, err := sup.StartJobSpec(JobSpec{Command: "cat", TTY: true, Stdin: true}); err != ErrStdinWithTTY {
+		t.Errorf("expected '%s', got '%v'", ErrStdinWithTTY, err)
+	}
+
+	jobID, err := sup.StartJobSpec(JobSpec{
+		Command: "cat",
+		Limits:  DefaultLimits,
+		TTY:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every attachment only gets the output produced after it
+	for _, input := range []string{"first", "second"} {
+		term, rd, err := sup.JobTerminal(jobID, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := io.WriteString(term, input+"\n"); err != nil {
+			t.Fatal(err)
+		}
+
+		// The line is echoed by the terminal, then written back by cat
+		expected := input + "\r\n" + input + "\r\n"
+		if out := readUntil(t, rd, expected); out != expected {
+			t.Errorf("expected '%s', got '%s'", expected, out)
+		}
+	}
+
+	// Replaying gets the whole output instead
+	_, rd, err := sup.JobTerminal(jobID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "first\r\nfirst\r\nsecond\r\nsecond\r\n"
+	if out := readUntil(t, rd, expected); out != expected {
+		t.Errorf("expected '%s', got '%s'", expected, out)
+	}
+
+	if err := sup.StopJob(jobID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Jobs without a terminal cannot be attached to
+	jobID, err = sup.StartJob("true")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := sup.JobTerminal(jobID, false); err != ErrNoTTY {
+		t.Errorf("expected '%s', got '%v'", ErrNoTTY, err)
+	}
+}
+
 func TestStartStopTwice(t *testing.T) {
 	sup := NewSupervisor()
 
@@ -222,3 +772,104 @@ func TestStdOutReadTwice(t *testing.T) {
 		t.Errorf("expected '%s', got '%s'", testString2, string(out))
 	}
 }
+
+func TestOutputDir(t *testing.T) {
+	sup := NewSupervisor()
+	sup.OutputDir = t.TempDir()
+
+	// More output than is kept in memory
+	jobID, err := sup.StartJob("seq", "100000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sup.WaitJob(context.Background(), jobID); err != nil {
+		t.Fatal(err)
+	}
+
+	rd, err := sup.JobStdOut(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := io.ReadAll(rd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := os.ReadFile(path.Join(sup.OutputDir, jobID, stdoutLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(expected) <= outputTailSize || !bytes.Equal(out, expected) {
+		t.Errorf("expected %d bytes, got %d", len(expected), len(out))
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(out), []byte("\n"))
+	if last := string(lines[len(lines)-1]); len(lines) != 100000 || last != "100000" {
+		t.Errorf("expected '100000' lines, got %d ending with '%s'", len(lines), last)
+	}
+}
+
+func TestOutputRetention(t *testing.T) {
+	sup := NewSupervisor()
+
+	jobID, err := sup.StartJobSpec(JobSpec{
+		Command:         "seq",
+		Arguments:       []string{"1000"},
+		Limits:          DefaultLimits,
+		OutputRetention: multipipe.Retention{First: 2, Last: 9},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sup.WaitJob(context.Background(), jobID); err != nil {
+		t.Fatal(err)
+	}
+
+	rd, err := sup.JobStdOut(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "1\n[output truncated, skipped 3882 bytes]\n999\n1000\n"
+	if out, err := io.ReadAll(rd); err != nil {
+		t.Fatal(err)
+	} else if string(out) != expected {
+		t.Errorf("expected '%s', got '%s'", expected, out)
+	}
+}
+
+func TestOutputLimit(t *testing.T) {
+	sup := NewSupervisor()
+
+	jobID, err := sup.StartJobSpec(JobSpec{
+		Command:         "yes",
+		Limits:          DefaultLimits,
+		OutputRetention: multipipe.Retention{Limit: 1000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	status, err := sup.WaitJob(ctx, jobID)
+	if err != nil {
+		t.Fatal(err)
+	} else if status.Status != StatusStopped {
+		t.Errorf("expected a stopped job, got '%+v'", status)
+	}
+
+	rd, err := sup.JobStdOut(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out, err := io.ReadAll(rd); err != multipipe.ErrOutputLimit || len(out) != 1000 {
+		t.Errorf("expected 1000 bytes and '%s', got %d (%v)", multipipe.ErrOutputLimit, len(out), err)
+	}
+}